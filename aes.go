@@ -4,7 +4,14 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"encoding/base64"
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	ErrInvalidPadding    = errors.New("invalid padding")
+	ErrInvalidCipherText = errors.New("invalid cipher text length")
+	ErrInvalidIV         = errors.New("invalid iv length")
 )
 
 func PKCS5Padding(cipherText []byte, blockSize int) []byte {
@@ -13,41 +20,105 @@ func PKCS5Padding(cipherText []byte, blockSize int) []byte {
 	return append(cipherText, padText...)
 }
 
+// 只按最后一个字节去掉填充,不限制块大小,填充长度越界时返回nil
+// Deprecated: 不校验填充内容,请使用PKCS7UnPadding
 func PKCS5UnPadding(origData []byte) []byte {
 	length := len(origData)
+	if length == 0 {
+		return nil
+	}
 	unPadding := int(origData[length-1])
+	if unPadding < 1 || unPadding > length {
+		return nil
+	}
 	return origData[:(length - unPadding)]
 }
 
+// 校验并去掉填充,校验耗时与填充内容无关
+// 数据长度不是blockSize的整数倍或任意填充字节不合法时返回ErrInvalidPadding
+func PKCS7UnPadding(origData []byte, blockSize int) ([]byte, error) {
+	length := len(origData)
+	if blockSize <= 0 || blockSize > 255 || length == 0 || length%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+
+	unPadding := int(origData[length-1])
+	good := subtle.ConstantTimeLessOrEq(1, unPadding) &
+		subtle.ConstantTimeLessOrEq(unPadding, blockSize)
+	for i := 1; i <= blockSize; i++ {
+		// 最后一块全部检查,只有位于填充范围内的字节参与比较
+		inPad := subtle.ConstantTimeLessOrEq(i, unPadding)
+		equal := subtle.ConstantTimeByteEq(origData[length-i], byte(unPadding))
+		good &= subtle.ConstantTimeSelect(inPad, equal, 1)
+	}
+	if good != 1 {
+		return nil, ErrInvalidPadding
+	}
+	return origData[:length-unPadding], nil
+}
+
 // https://github.com/polaris1119/myblog_article_code/blob/master/aes/aes.go
 func AesEncrypt(origData, key, iv []byte) (string, error) {
-	block, err := aes.NewCipher(key)
+	return AesEncryptCodec(origData, key, iv, StdCodec)
+}
+
+func AesDecrypt(cryptEd string, key, iv []byte) ([]byte, error) {
+	return AesDecryptCodec(cryptEd, key, iv, StdCodec)
+}
+
+// 加密并使用c编码密文
+func AesEncryptCodec(origData, key, iv []byte, c Codec) (string, error) {
+	cryptEd, err := AesEncryptBytes(origData, key, iv)
 	if err != nil {
 		return "", err
 	}
+	return c.EncodeToString(cryptEd), nil
+}
+
+// 使用c解码密文后解密
+func AesDecryptCodec(cryptEd string, key, iv []byte, c Codec) ([]byte, error) {
+	byt, err := c.DecodeString(cryptEd)
+	if err != nil {
+		return nil, err
+	}
+	return AesDecryptBytes(byt, key, iv)
+}
+
+// 加密,返回原始密文字节
+func AesEncryptBytes(origData, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 
 	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return nil, ErrInvalidIV
+	}
 	origData = PKCS5Padding(origData, blockSize)
 	blockMode := cipher.NewCBCEncrypter(block, iv)
 	cryptEd := make([]byte, len(origData))
 	blockMode.CryptBlocks(cryptEd, origData)
-	return base64.StdEncoding.EncodeToString(cryptEd), nil
+	return cryptEd, nil
 }
 
-func AesDecrypt(cryptEd string, key, iv []byte) ([]byte, error) {
-	byt, err := base64.StdEncoding.DecodeString(cryptEd)
+// 解密原始密文字节
+func AesDecryptBytes(cryptEd, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return nil, ErrInvalidIV
+	}
+	if len(cryptEd) == 0 || len(cryptEd)%blockSize != 0 {
+		return nil, ErrInvalidCipherText
 	}
 
 	blockMode := cipher.NewCBCDecrypter(block, iv)
-	origData := make([]byte, len(byt))
-	blockMode.CryptBlocks(origData, byt)
-	origData = PKCS5UnPadding(origData)
-	return origData, nil
+	origData := make([]byte, len(cryptEd))
+	blockMode.CryptBlocks(origData, cryptEd)
+	return PKCS7UnPadding(origData, blockSize)
 }
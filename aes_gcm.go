@@ -0,0 +1,74 @@
+package golibs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// AesGcmSeal输出格式: 版本号(1字节) | 随机nonce(12字节) | 密文 | tag(16字节)
+const AesGcmVersion byte = 1
+
+var (
+	ErrAesGcmVersion  = errors.New("aes gcm version error")
+	ErrAesGcmEnvelope = errors.New("aes gcm envelope error")
+)
+
+// 认证加密,每次加密随机生成nonce,additionalData可为nil
+func AesGcmSeal(origData, key, additionalData []byte) ([]byte, error) {
+	return aesGcmSeal([]byte{AesGcmVersion}, origData, key, additionalData)
+}
+
+// 认证解密,密文或additionalData被篡改时返回错误
+func AesGcmOpen(cryptEd, key, additionalData []byte) ([]byte, error) {
+	if len(cryptEd) == 0 {
+		return nil, ErrAesGcmEnvelope
+	}
+	if cryptEd[0] != AesGcmVersion {
+		return nil, ErrAesGcmVersion
+	}
+	return aesGcmOpen(cryptEd[:1], cryptEd[1:], key, additionalData)
+}
+
+func newAesGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// header原样放在输出开头,并和additionalData一起参与认证
+func aesGcmSeal(header, origData, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAesGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	out := make([]byte, len(header)+nonceSize,
+		len(header)+nonceSize+len(origData)+aead.Overhead())
+	copy(out, header)
+	nonce := out[len(header):]
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, nonce, origData, append(header[:len(header):len(header)], additionalData...)), nil
+}
+
+// body为去掉header后的 nonce | 密文 | tag
+func aesGcmOpen(header, body, key, additionalData []byte) ([]byte, error) {
+	aead, err := newAesGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(body) < nonceSize+aead.Overhead() {
+		return nil, ErrAesGcmEnvelope
+	}
+	return aead.Open(nil, body[:nonceSize], body[nonceSize:],
+		append(header[:len(header):len(header)], additionalData...))
+}
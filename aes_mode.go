@@ -0,0 +1,128 @@
+package golibs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+type AesMode int
+
+const (
+	AesCBC AesMode = iota
+	AesCTR
+	AesCFB // CFB128,对应java的AES/CFB
+	AesOFB
+)
+
+var ErrAesMode = errors.New("aes mode error")
+
+// 可选择分组模式的AES加解密,用于和其他语言的服务互通
+// 需要数据完整性校验时请使用AesGcmSeal
+type AesCipher struct {
+	Mode AesMode
+	Key  []byte
+	IV   []byte
+
+	// CTR/CFB/OFB默认不填充,为true时使用PKCS#7填充,CBC总是填充
+	Padding bool
+	// 文本编码方式,为nil时使用StdCodec
+	Codec Codec
+}
+
+func (c *AesCipher) codec() Codec {
+	if c.Codec == nil {
+		return StdCodec
+	}
+	return c.Codec
+}
+
+func (c *AesCipher) Encrypt(origData []byte) (string, error) {
+	cryptEd, err := c.EncryptBytes(origData)
+	if err != nil {
+		return "", err
+	}
+	return c.codec().EncodeToString(cryptEd), nil
+}
+
+func (c *AesCipher) Decrypt(cryptEd string) ([]byte, error) {
+	byt, err := c.codec().DecodeString(cryptEd)
+	if err != nil {
+		return nil, err
+	}
+	return c.DecryptBytes(byt)
+}
+
+func (c *AesCipher) EncryptBytes(origData []byte) ([]byte, error) {
+	if c.Mode == AesCBC {
+		return AesEncryptBytes(origData, c.Key, c.IV)
+	}
+	stream, err := c.stream(true)
+	if err != nil {
+		return nil, err
+	}
+	if c.Padding {
+		origData = PKCS5Padding(origData, aes.BlockSize)
+	}
+	cryptEd := make([]byte, len(origData))
+	stream.XORKeyStream(cryptEd, origData)
+	return cryptEd, nil
+}
+
+func (c *AesCipher) DecryptBytes(cryptEd []byte) ([]byte, error) {
+	if c.Mode == AesCBC {
+		return AesDecryptBytes(cryptEd, c.Key, c.IV)
+	}
+	stream, err := c.stream(false)
+	if err != nil {
+		return nil, err
+	}
+	origData := make([]byte, len(cryptEd))
+	stream.XORKeyStream(origData, cryptEd)
+	if c.Padding {
+		return PKCS7UnPadding(origData, aes.BlockSize)
+	}
+	return origData, nil
+}
+
+func (c *AesCipher) stream(encrypt bool) (cipher.Stream, error) {
+	block, err := aes.NewCipher(c.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.IV) != block.BlockSize() {
+		return nil, ErrInvalidIV
+	}
+
+	switch c.Mode {
+	case AesCTR:
+		return cipher.NewCTR(block, c.IV), nil
+	case AesCFB:
+		if encrypt {
+			return cipher.NewCFBEncrypter(block, c.IV), nil
+		}
+		return cipher.NewCFBDecrypter(block, c.IV), nil
+	case AesOFB:
+		return cipher.NewOFB(block, c.IV), nil
+	}
+	return nil, ErrAesMode
+}
+
+// 警告: ECB模式相同明文块得到相同密文块,不安全
+// 这里只提供解密,用于读取遗留系统中的数据(PKCS#7填充),不提供加密
+func AesEcbDecryptLegacy(cryptEd, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := block.BlockSize()
+	if len(cryptEd) == 0 || len(cryptEd)%blockSize != 0 {
+		return nil, ErrInvalidCipherText
+	}
+	origData := make([]byte, len(cryptEd))
+	for i := 0; i < len(cryptEd); i += blockSize {
+		block.Decrypt(origData[i:i+blockSize], cryptEd[i:i+blockSize])
+	}
+	return PKCS7UnPadding(origData, blockSize)
+}
@@ -0,0 +1,182 @@
+package golibs
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+/*
+口令加密格式
+
+	版本号(1字节) | kdf类型(1字节) | kdf参数(3*4字节) | salt长度(1字节) | salt | nonce | 密文 | tag
+
+头部记录了派生密钥所需的全部参数,解密时只需要口令,头部同时参与GCM认证
+*/
+const (
+	AesPassVersion byte = 1
+
+	aesPassKeyLen   = 32
+	aesPassSaltLen  = 16
+	aesPassFixedLen = 1 + 1 + 3*4 + 1
+)
+
+type KdfType byte
+
+const (
+	KdfScrypt   KdfType = 1
+	KdfArgon2id KdfType = 2
+	KdfPBKDF2   KdfType = 3 // 兼容用途,使用HMAC-SHA256
+)
+
+// 各字段含义由Type决定
+//
+//	scrypt:   Cost=N, Memory=r, Threads=p
+//	argon2id: Cost=迭代次数, Memory=内存大小(KiB), Threads=并行度
+//	pbkdf2:   Cost=迭代次数
+type KdfParams struct {
+	Type    KdfType
+	Cost    uint32
+	Memory  uint32
+	Threads uint32
+}
+
+var (
+	ScryptParams   = KdfParams{Type: KdfScrypt, Cost: 1 << 15, Memory: 8, Threads: 1}
+	Argon2idParams = KdfParams{Type: KdfArgon2id, Cost: 3, Memory: 64 * 1024, Threads: 4}
+	PBKDF2Params   = KdfParams{Type: KdfPBKDF2, Cost: 600000}
+
+	ErrKdfParams      = errors.New("kdf params error")
+	ErrAesPassVersion = errors.New("aes pass version error")
+	ErrAesPassHeader  = errors.New("aes pass header error")
+)
+
+// 派生密钥允许使用的最大内存
+const kdfMaxMemory = 1 << 30
+
+// 检查参数范围,避免解密时被恶意头部耗尽内存或CPU
+func (p *KdfParams) check() error {
+	switch p.Type {
+	case KdfScrypt:
+		if !scryptParamsOk(uint64(p.Cost), uint64(p.Memory), uint64(p.Threads)) {
+			return ErrKdfParams
+		}
+	case KdfArgon2id:
+		if p.Cost == 0 || p.Cost > 64 || p.Memory < 8 || uint64(p.Memory)*1024 > kdfMaxMemory ||
+			p.Threads == 0 || p.Threads > 255 {
+			return ErrKdfParams
+		}
+	case KdfPBKDF2:
+		if p.Cost == 0 || p.Cost > 1<<24 {
+			return ErrKdfParams
+		}
+	default:
+		return ErrKdfParams
+	}
+	return nil
+}
+
+// scrypt需要分配128*N*r和128*r*p字节内存,计算量与N*r*p成正比
+func scryptParamsOk(n, r, p uint64) bool {
+	return n >= 2 && n <= 1<<20 && n&(n-1) == 0 &&
+		r > 0 && 128*n*r <= kdfMaxMemory &&
+		p > 0 && p <= 16 && 128*r*p <= kdfMaxMemory
+}
+
+// 通过口令和salt派生长度为keyLen的密钥
+func DeriveKey(passphrase, salt []byte, keyLen int, params *KdfParams) ([]byte, error) {
+	if err := params.check(); err != nil {
+		return nil, err
+	}
+	switch params.Type {
+	case KdfScrypt:
+		return scrypt.Key(passphrase, salt, int(params.Cost),
+			int(params.Memory), int(params.Threads), keyLen)
+	case KdfArgon2id:
+		return argon2.IDKey(passphrase, salt, params.Cost,
+			params.Memory, uint8(params.Threads), uint32(keyLen)), nil
+	default:
+		return pbkdf2.Key(passphrase, salt, int(params.Cost), keyLen, sha256.New), nil
+	}
+}
+
+// 使用口令加密,params为nil时使用ScryptParams
+func AesPassEncrypt(origData, passphrase []byte, params *KdfParams) (string, error) {
+	return AesPassEncryptCodec(origData, passphrase, params, StdCodec)
+}
+
+// 使用口令解密,kdf参数从密文头部读取
+func AesPassDecrypt(cryptEd string, passphrase []byte) ([]byte, error) {
+	return AesPassDecryptCodec(cryptEd, passphrase, StdCodec)
+}
+
+func AesPassEncryptCodec(origData, passphrase []byte, params *KdfParams, c Codec) (string, error) {
+	cryptEd, err := AesPassEncryptBytes(origData, passphrase, params)
+	if err != nil {
+		return "", err
+	}
+	return c.EncodeToString(cryptEd), nil
+}
+
+func AesPassDecryptCodec(cryptEd string, passphrase []byte, c Codec) ([]byte, error) {
+	byt, err := c.DecodeString(cryptEd)
+	if err != nil {
+		return nil, err
+	}
+	return AesPassDecryptBytes(byt, passphrase)
+}
+
+func AesPassEncryptBytes(origData, passphrase []byte, params *KdfParams) ([]byte, error) {
+	if params == nil {
+		params = &ScryptParams
+	}
+
+	header := make([]byte, aesPassFixedLen+aesPassSaltLen)
+	header[0] = AesPassVersion
+	header[1] = byte(params.Type)
+	binary.BigEndian.PutUint32(header[2:], params.Cost)
+	binary.BigEndian.PutUint32(header[6:], params.Memory)
+	binary.BigEndian.PutUint32(header[10:], params.Threads)
+	header[14] = aesPassSaltLen
+	salt := header[aesPassFixedLen:]
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	key, err := DeriveKey(passphrase, salt, aesPassKeyLen, params)
+	if err != nil {
+		return nil, err
+	}
+	return aesGcmSeal(header, origData, key, nil)
+}
+
+func AesPassDecryptBytes(cryptEd, passphrase []byte) ([]byte, error) {
+	if len(cryptEd) < aesPassFixedLen {
+		return nil, ErrAesPassHeader
+	}
+	if cryptEd[0] != AesPassVersion {
+		return nil, ErrAesPassVersion
+	}
+	headerLen := aesPassFixedLen + int(cryptEd[14])
+	if cryptEd[14] == 0 || len(cryptEd) < headerLen {
+		return nil, ErrAesPassHeader
+	}
+
+	params := &KdfParams{
+		Type:    KdfType(cryptEd[1]),
+		Cost:    binary.BigEndian.Uint32(cryptEd[2:]),
+		Memory:  binary.BigEndian.Uint32(cryptEd[6:]),
+		Threads: binary.BigEndian.Uint32(cryptEd[10:]),
+	}
+	key, err := DeriveKey(passphrase, cryptEd[aesPassFixedLen:headerLen], aesPassKeyLen, params)
+	if err != nil {
+		return nil, err
+	}
+	return aesGcmOpen(cryptEd[:headerLen], cryptEd[headerLen:], key, nil)
+}
@@ -0,0 +1,320 @@
+package golibs
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+/*
+流式加密格式
+
+	头部: 版本号(1字节) | 分块大小(4字节) | nonce前缀(7字节)
+	分块: 密文 | tag(16字节), 除最后一块外每块明文长度都等于分块大小
+
+每块nonce = nonce前缀 | 块序号(4字节) | 结束标记(1字节),头部作为每块的附加认证数据
+最后一块带结束标记,因此截断、重排、拼接都会导致解密失败
+*/
+const (
+	AesStreamVersion   byte = 1
+	AesStreamChunkSize      = 64 * 1024
+
+	aesStreamMaxChunk   = 16 * 1024 * 1024
+	aesStreamPrefixSize = 7
+	aesStreamHeaderSize = 1 + 4 + aesStreamPrefixSize
+)
+
+var (
+	ErrAesStreamVersion   = errors.New("aes stream version error")
+	ErrAesStreamHeader    = errors.New("aes stream header error")
+	ErrAesStreamTruncated = errors.New("aes stream truncated")
+	ErrAesStreamClosed    = errors.New("aes stream closed")
+	ErrAesStreamSameFile  = errors.New("aes stream src and dst are the same file")
+	ErrAesStreamTooLong   = errors.New("aes stream too long")
+)
+
+type aesStream struct {
+	aead    cipher.AEAD
+	header  []byte
+	nonce   []byte
+	counter uint32
+}
+
+func (s *aesStream) nextNonce(last bool) ([]byte, error) {
+	if s.counter == ^uint32(0) {
+		return nil, ErrAesStreamTooLong
+	}
+	binary.BigEndian.PutUint32(s.nonce[aesStreamPrefixSize:], s.counter)
+	s.nonce[len(s.nonce)-1] = 0
+	if last {
+		s.nonce[len(s.nonce)-1] = 1
+	}
+	s.counter++
+	return s.nonce, nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+type aesStreamWriter struct {
+	aesStream
+	w      io.Writer
+	buf    []byte
+	out    []byte
+	err    error
+	closed bool
+}
+
+// 使用默认分块大小创建加密writer
+func NewAesEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	return NewAesEncryptWriterSize(w, key, AesStreamChunkSize)
+}
+
+// 创建加密writer,必须调用Close写入最后一块,Close不会关闭w
+func NewAesEncryptWriterSize(w io.Writer, key []byte, chunkSize int) (io.WriteCloser, error) {
+	if chunkSize <= 0 || chunkSize > aesStreamMaxChunk {
+		return nil, ErrAesStreamHeader
+	}
+	aead, err := newAesGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, aesStreamHeaderSize)
+	header[0] = AesStreamVersion
+	binary.BigEndian.PutUint32(header[1:], uint32(chunkSize))
+	if _, err = io.ReadFull(rand.Reader, header[5:]); err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+
+	sw := &aesStreamWriter{
+		aesStream: aesStream{
+			aead:   aead,
+			header: header,
+			nonce:  make([]byte, aead.NonceSize()),
+		},
+		w:   w,
+		buf: make([]byte, 0, chunkSize),
+		out: make([]byte, 0, chunkSize+aead.Overhead()),
+	}
+	copy(sw.nonce, header[5:])
+	return sw, nil
+}
+
+func (w *aesStreamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrAesStreamClosed
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	var n int
+	for len(p) > 0 {
+		// 缓冲区满了且还有数据时才写出,保证最后一块在Close时才带结束标记
+		if len(w.buf) == cap(w.buf) {
+			if w.err = w.flush(false); w.err != nil {
+				return n, w.err
+			}
+		}
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (w *aesStreamWriter) flush(last bool) error {
+	nonce, err := w.nextNonce(last)
+	if err != nil {
+		return err
+	}
+	w.out = w.aead.Seal(w.out[:0], nonce, w.buf, w.header)
+	w.buf = w.buf[:0]
+	_, err = w.w.Write(w.out)
+	return err
+}
+
+// 写入最后一块,不会关闭底层writer
+func (w *aesStreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.flush(true)
+	return w.err
+}
+
+/*----------------------------------------------------------------------------*/
+
+type aesStreamReader struct {
+	aesStream
+	r     *bufio.Reader
+	frame []byte
+	buf   []byte // 解密输出,不复用frame以便校验失败后重试
+	plain []byte
+	done  bool
+	err   error
+}
+
+// 创建解密reader,每块校验通过后才返回数据
+// 注意: 流被截断时,已经返回的数据是之前通过校验的块,截断在分块边界上时最后返回ErrAesStreamTruncated
+// 截断在分块中间时无法与篡改区分,返回校验错误
+func NewAesDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	aead, err := newAesGcm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, aesStreamHeaderSize)
+	if _, err = io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrAesStreamHeader
+		}
+		return nil, err
+	}
+	if header[0] != AesStreamVersion {
+		return nil, ErrAesStreamVersion
+	}
+	chunkSize := binary.BigEndian.Uint32(header[1:])
+	if chunkSize == 0 || chunkSize > aesStreamMaxChunk {
+		return nil, ErrAesStreamHeader
+	}
+
+	sr := &aesStreamReader{
+		aesStream: aesStream{
+			aead:   aead,
+			header: header,
+			nonce:  make([]byte, aead.NonceSize()),
+		},
+		r:     bufio.NewReader(r),
+		frame: make([]byte, int(chunkSize)+aead.Overhead()),
+		buf:   make([]byte, 0, chunkSize),
+	}
+	copy(sr.nonce, header[5:])
+	return sr, nil
+}
+
+func (r *aesStreamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.readFrame()
+	}
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+func (r *aesStreamReader) readFrame() error {
+	n, err := io.ReadFull(r.r, r.frame)
+	last, full := false, err == nil
+	switch err {
+	case nil: // 整块数据,读不到后续数据说明是最后一块
+		if _, err = r.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return ErrAesStreamTruncated
+	default:
+		return err
+	}
+	if n < r.aead.Overhead() {
+		return ErrAesStreamTruncated
+	}
+
+	nonce, err := r.nextNonce(last)
+	if err != nil {
+		return err
+	}
+	r.plain, err = r.aead.Open(r.buf[:0], nonce, r.frame[:n], r.header)
+	if err != nil {
+		if last && full {
+			// 整块数据后遇到EOF,能作为中间块通过校验说明流被截断在分块边界上
+			r.counter--
+			nonce, _ = r.nextNonce(false)
+			if _, e := r.aead.Open(r.buf[:0], nonce, r.frame[:n], r.header); e == nil {
+				return ErrAesStreamTruncated
+			}
+		}
+		return err // 密钥错误,数据被篡改或在分块中间截断
+	}
+	r.done = last
+	return nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+// 流式加密文件,src和dst不能是同一个文件,失败时不会修改已存在的dst
+func AesEncryptFile(src, dst string, key []byte) error {
+	return aesStreamFile(src, dst, func(w io.Writer, r io.Reader) error {
+		sw, err := NewAesEncryptWriter(w, key)
+		if err != nil {
+			return err
+		}
+		if _, err = io.Copy(sw, r); err != nil {
+			return err
+		}
+		return sw.Close()
+	})
+}
+
+// 流式解密文件,全部数据校验通过后才替换dst,失败时不会修改已存在的dst
+func AesDecryptFile(src, dst string, key []byte) error {
+	return aesStreamFile(src, dst, func(w io.Writer, r io.Reader) error {
+		sr, err := NewAesDecryptReader(r, key)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, sr)
+		return err
+	})
+}
+
+// 先写入dst同目录下的临时文件,成功后重命名覆盖dst,失败时dst保持不变
+func aesStreamFile(src, dst string, fn func(w io.Writer, r io.Reader) error) error {
+	fr, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	if si, err := fr.Stat(); err == nil {
+		if di, err := os.Stat(dst); err == nil && os.SameFile(si, di) {
+			return ErrAesStreamSameFile
+		}
+	}
+
+	tmp, err := createTempFile(dst, 0600, func(fw *os.File) error {
+		bw := bufio.NewWriter(fw)
+		if err := fn(bw, bufio.NewReader(fr)); err != nil {
+			return err
+		}
+		return bw.Flush()
+	})
+	if err != nil {
+		return err
+	}
+	if err = renameFile(tmp, dst, true); err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
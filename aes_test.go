@@ -0,0 +1,312 @@
+package golibs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Aes
+
+func TestAesGcm(t *testing.T) {
+	Convey("test aes gcm", t, func() {
+		key := bytes.Repeat([]byte{'k'}, 32)
+		aad := []byte("header")
+		cryptEd, err := AesGcmSeal([]byte("hello world"), key, aad)
+		So(err, ShouldBeNil)
+		So(cryptEd[0], ShouldEqual, AesGcmVersion)
+
+		origData, err := AesGcmOpen(cryptEd, key, aad)
+		So(err, ShouldBeNil)
+		So(string(origData), ShouldEqual, "hello world")
+
+		_, err = AesGcmOpen(cryptEd, key, []byte("other"))
+		So(err, ShouldNotBeNil)
+
+		cryptEd[len(cryptEd)-1] ^= 1
+		_, err = AesGcmOpen(cryptEd, key, aad)
+		So(err, ShouldNotBeNil)
+
+		_, err = AesGcmOpen(cryptEd[:5], key, aad)
+		So(err, ShouldEqual, ErrAesGcmEnvelope)
+	})
+}
+
+func TestAesStream(t *testing.T) {
+	Convey("test aes stream", t, func() {
+		key := bytes.Repeat([]byte{'k'}, 16)
+		for _, size := range []int{0, 1, 16, 17, 48} {
+			origData := bytes.Repeat([]byte{'a'}, size)
+			var buf bytes.Buffer
+			w, err := NewAesEncryptWriterSize(&buf, key, 16)
+			So(err, ShouldBeNil)
+			_, err = w.Write(origData)
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			cryptEd := buf.Bytes()
+
+			r, err := NewAesDecryptReader(bytes.NewReader(cryptEd), key)
+			So(err, ShouldBeNil)
+			data, err := io.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, origData)
+
+			if size > 16 { // 在第一块后截断
+				r, err = NewAesDecryptReader(bytes.NewReader(cryptEd[:aesStreamHeaderSize+32]), key)
+				So(err, ShouldBeNil)
+				_, err = io.ReadAll(r)
+				So(err, ShouldEqual, ErrAesStreamTruncated)
+			}
+
+			// 密钥错误或数据被篡改不能报告为截断
+			r, err = NewAesDecryptReader(bytes.NewReader(cryptEd), bytes.Repeat([]byte{'x'}, 16))
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(r)
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotEqual, ErrAesStreamTruncated)
+
+			tampered := append([]byte(nil), cryptEd...)
+			tampered[len(tampered)-1] ^= 1
+			r, err = NewAesDecryptReader(bytes.NewReader(tampered), key)
+			So(err, ShouldBeNil)
+			_, err = io.ReadAll(r)
+			So(err, ShouldNotBeNil)
+			So(err, ShouldNotEqual, ErrAesStreamTruncated)
+		}
+	})
+}
+
+func TestAesStreamFile(t *testing.T) {
+	Convey("test aes stream file", t, func() {
+		dir := t.TempDir()
+		key := bytes.Repeat([]byte{'k'}, 16)
+		src := filepath.Join(dir, "src.txt")
+		enc := filepath.Join(dir, "src.enc")
+		dst := filepath.Join(dir, "dst.txt")
+		So(os.WriteFile(src, []byte("hello world"), 0600), ShouldBeNil)
+
+		So(AesEncryptFile(src, enc, key), ShouldBeNil)
+		So(AesDecryptFile(enc, dst, key), ShouldBeNil)
+		data, err := os.ReadFile(dst)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "hello world")
+
+		// 同一个文件不能作为输入和输出
+		So(AesEncryptFile(src, src, key), ShouldEqual, ErrAesStreamSameFile)
+		So(AesDecryptFile(enc, enc, key), ShouldEqual, ErrAesStreamSameFile)
+		data, err = os.ReadFile(src)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "hello world")
+
+		// 解密失败时已存在的dst保持不变,也不留下临时文件
+		So(os.WriteFile(dst, []byte("keep"), 0600), ShouldBeNil)
+		So(AesDecryptFile(enc, dst, bytes.Repeat([]byte{'x'}, 16)), ShouldNotBeNil)
+		data, err = os.ReadFile(dst)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "keep")
+		names, err := os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(len(names), ShouldEqual, 3)
+	})
+}
+
+func TestAesCbc(t *testing.T) {
+	Convey("test aes cbc padding", t, func() {
+		key, iv := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+		cryptEd, err := AesEncrypt([]byte("hello world"), key, iv)
+		So(err, ShouldBeNil)
+		origData, err := AesDecrypt(cryptEd, key, iv)
+		So(err, ShouldBeNil)
+		So(string(origData), ShouldEqual, "hello world")
+
+		_, err = AesDecrypt(cryptEd, []byte("0123456789abcdeX"), iv)
+		So(err, ShouldEqual, ErrInvalidPadding)
+		_, err = AesDecrypt("", key, iv)
+		So(err, ShouldEqual, ErrInvalidCipherText)
+		_, err = AesDecrypt(cryptEd, key, iv[:8])
+		So(err, ShouldEqual, ErrInvalidIV)
+
+		_, err = PKCS7UnPadding(nil, 16)
+		So(err, ShouldEqual, ErrInvalidPadding)
+		_, err = PKCS7UnPadding(append(bytes.Repeat([]byte{1}, 13), 2, 3, 3), 16)
+		So(err, ShouldEqual, ErrInvalidPadding)
+		_, err = PKCS7UnPadding(append(bytes.Repeat([]byte{1}, 15), 17), 16)
+		So(err, ShouldEqual, ErrInvalidPadding)
+		origData, err = PKCS7UnPadding(append(bytes.Repeat([]byte{1}, 13), 3, 3, 3), 16)
+		So(err, ShouldBeNil)
+		So(len(origData), ShouldEqual, 13)
+
+		// PKCS5UnPadding不限制块大小,例如DES的8字节块
+		So(string(PKCS5UnPadding([]byte("hello\x03\x03\x03"))), ShouldEqual, "hello")
+		So(PKCS5UnPadding(nil), ShouldBeNil)
+		So(PKCS5UnPadding([]byte{1, 9}), ShouldBeNil)
+	})
+}
+
+func TestAesPass(t *testing.T) {
+	Convey("test aes passphrase", t, func() {
+		pass := []byte("passphrase")
+		for _, params := range []*KdfParams{
+			{Type: KdfScrypt, Cost: 1 << 10, Memory: 8, Threads: 1},
+			{Type: KdfArgon2id, Cost: 1, Memory: 64, Threads: 1},
+			{Type: KdfPBKDF2, Cost: 1000},
+		} {
+			cryptEd, err := AesPassEncrypt([]byte("hello world"), pass, params)
+			So(err, ShouldBeNil)
+			origData, err := AesPassDecrypt(cryptEd, pass)
+			So(err, ShouldBeNil)
+			So(string(origData), ShouldEqual, "hello world")
+
+			_, err = AesPassDecrypt(cryptEd, []byte("wrong"))
+			So(err, ShouldNotBeNil)
+		}
+
+		_, err := AesPassEncrypt(nil, pass, &KdfParams{Type: KdfScrypt, Cost: 3})
+		So(err, ShouldEqual, ErrKdfParams)
+
+		// 伪造头部中的scrypt参数,N=2^20,r=2^20需要128TiB内存
+		cryptEd, err := AesPassEncryptBytes([]byte("hello"), pass, &KdfParams{Type: KdfScrypt, Cost: 1 << 10, Memory: 8, Threads: 1})
+		So(err, ShouldBeNil)
+		binary.BigEndian.PutUint32(cryptEd[2:], 1<<20)
+		binary.BigEndian.PutUint32(cryptEd[6:], 1<<20)
+		_, err = AesPassDecryptBytes(cryptEd, pass)
+		So(err, ShouldEqual, ErrKdfParams)
+		binary.BigEndian.PutUint32(cryptEd[2:], 1<<10)
+		binary.BigEndian.PutUint32(cryptEd[6:], 8)
+		binary.BigEndian.PutUint32(cryptEd[10:], 1<<20)
+		_, err = AesPassDecryptBytes(cryptEd, pass)
+		So(err, ShouldEqual, ErrKdfParams)
+	})
+}
+
+func TestAesCodec(t *testing.T) {
+	Convey("test aes codec", t, func() {
+		key, iv := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+		for _, c := range []Codec{StdCodec, URLCodec, RawStdCodec, RawURLCodec, HexCodec} {
+			cryptEd, err := AesEncryptCodec([]byte("hello world"), key, iv, c)
+			So(err, ShouldBeNil)
+			origData, err := AesDecryptCodec(cryptEd, key, iv, c)
+			So(err, ShouldBeNil)
+			So(string(origData), ShouldEqual, "hello world")
+		}
+	})
+}
+
+func TestAesMode(t *testing.T) {
+	Convey("test aes mode", t, func() {
+		key, iv := []byte("0123456789abcdef"), []byte("fedcba9876543210")
+		for _, mode := range []AesMode{AesCBC, AesCTR, AesCFB, AesOFB} {
+			for _, padding := range []bool{false, true} {
+				c := &AesCipher{Mode: mode, Key: key, IV: iv, Padding: padding, Codec: HexCodec}
+				cryptEd, err := c.Encrypt([]byte("hello world"))
+				So(err, ShouldBeNil)
+				origData, err := c.Decrypt(cryptEd)
+				So(err, ShouldBeNil)
+				So(string(origData), ShouldEqual, "hello world")
+			}
+		}
+
+		block, err := aes.NewCipher(key)
+		So(err, ShouldBeNil)
+		origData := PKCS5Padding([]byte("legacy ecb data"), aes.BlockSize)
+		cryptEd := make([]byte, len(origData))
+		block.Encrypt(cryptEd, origData)
+		origData, err = AesEcbDecryptLegacy(cryptEd, key)
+		So(err, ShouldBeNil)
+		So(string(origData), ShouldEqual, "legacy ecb data")
+	})
+
+	// NIST SP 800-38A F.1.2, F.3.14, F.4.2, F.5.2
+	Convey("test aes mode known answer", t, func() {
+		key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+		plain := "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+			"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+		for _, v := range []struct {
+			mode   AesMode
+			iv     string
+			cipher string
+		}{
+			{AesCFB, "000102030405060708090a0b0c0d0e0f",
+				"3b3fd92eb72dad20333449f8e83cfb4ac8a64537a0b3a93fcde3cdad9f1ce58b" +
+					"26751f67a3cbb140b1808cf187a4f4dfc04b05357c5d1c0eeac4c66f9ff7f2e6"},
+			{AesOFB, "000102030405060708090a0b0c0d0e0f",
+				"3b3fd92eb72dad20333449f8e83cfb4a7789508d16918f03f53c52dac54ed825" +
+					"9740051e9c5fecf64344f7a82260edcc304c6528f659c77866a510d9c1d6ae5e"},
+			{AesCTR, "f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+				"874d6191b620e3261bef6864990db6ce9806f66b7970fdff8617187bb9fffdff" +
+					"5ae4df3edbd5d35e5b4f09020db03eab1e031dda2fbe03d1792170a0f3009cee"},
+		} {
+			iv, _ := hex.DecodeString(v.iv)
+			c := &AesCipher{Mode: v.mode, Key: key, IV: iv, Codec: HexCodec}
+			plainData, _ := hex.DecodeString(plain)
+			cryptEd, err := c.Encrypt(plainData)
+			So(err, ShouldBeNil)
+			So(cryptEd, ShouldEqual, v.cipher)
+			origData, err := c.Decrypt(v.cipher)
+			So(err, ShouldBeNil)
+			So(hex.EncodeToString(origData), ShouldEqual, plain)
+		}
+
+		// 多块ECB,最后一块为PKCS#7填充块
+		cryptEd, _ := hex.DecodeString("3ad77bb40d7a3660a89ecaf32466ef97f5d3d58503b9699de785895a96fdbaaf" +
+			"43b1cd7f598ece23881b00e3ed0306887b0c785e27e8ad3f8223207104725dd4" +
+			"a254be88e037ddd9d79fb6411c3f9df8")
+		origData, err := AesEcbDecryptLegacy(cryptEd, key)
+		So(err, ShouldBeNil)
+		So(hex.EncodeToString(origData), ShouldEqual, plain)
+	})
+}
+
+func TestAesKeyRing(t *testing.T) {
+	Convey("test aes key ring", t, func() {
+		k := NewKeyRing("keys.json")
+		_, err := k.Encrypt([]byte("hello"), nil)
+		So(err, ShouldEqual, ErrKeyRingNoActive)
+
+		err = k.Modify([]byte(`{"active":"q1","keys":{"q1":"MDEyMzQ1Njc4OWFiY2RlZg=="}}`))
+		So(err, ShouldBeNil)
+		old, err := k.Encrypt([]byte("hello"), nil)
+		So(err, ShouldBeNil)
+
+		pemData := `-----BEGIN AES KEY-----
+Id: q1
+
+MDEyMzQ1Njc4OWFiY2RlZg==
+-----END AES KEY-----
+-----BEGIN AES KEY-----
+Id: q2
+Active: true
+
+ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=
+-----END AES KEY-----
+`
+		So(k.Modify([]byte(pemData)), ShouldBeNil)
+		So(k.Active(), ShouldEqual, "q2")
+		So(k.Modify([]byte("{}")), ShouldNotBeNil)
+		So(k.Active(), ShouldEqual, "q2")
+
+		cryptEd, err := k.Encrypt([]byte("world"), nil)
+		So(err, ShouldBeNil)
+		id, err := KeyRingId(cryptEd)
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, "q2")
+
+		origData, err := k.Decrypt(old, nil)
+		So(err, ShouldBeNil)
+		So(string(origData), ShouldEqual, "hello")
+		origData, err = k.Decrypt(cryptEd, nil)
+		So(err, ShouldBeNil)
+		So(string(origData), ShouldEqual, "world")
+
+		cryptEd[2] = 'x'
+		_, err = k.Decrypt(cryptEd, nil)
+		So(err, ShouldEqual, ErrKeyRingNotFound)
+	})
+}
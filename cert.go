@@ -0,0 +1,221 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+var ErrCertNotCA = errors.New("certificate is not ca")
+
+type CertOptions struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	IPAddresses  []net.IP
+
+	NotBefore time.Time     // 为零值时使用当前时间前1分钟,兼容少量的时钟误差
+	ValidFor  time.Duration // 为0时CA有效期10年,其他证书1年
+
+	// 证书用途,都为false时作为服务端证书
+	Server bool
+	Client bool
+
+	// 证书私钥,为nil时生成ECDSA P-256私钥
+	Key crypto.Signer
+}
+
+// 证书及其私钥
+type CertKeyPair struct {
+	Cert    *x509.Certificate
+	Key     crypto.Signer
+	CertPem []byte
+	KeyPem  []byte
+}
+
+// 生成自签名的本地CA
+func GenCA(opt *CertOptions) (*CertKeyPair, error) {
+	tmpl, key, err := certTemplate(opt, 10*365*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.MaxPathLenZero = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	return createCert(tmpl, tmpl, key, key)
+}
+
+// 生成自签名证书
+func GenSelfSignedCert(opt *CertOptions) (*CertKeyPair, error) {
+	tmpl, key, err := leafTemplate(opt)
+	if err != nil {
+		return nil, err
+	}
+	return createCert(tmpl, tmpl, key, key)
+}
+
+// 使用CA签发服务端或客户端证书
+func (ca *CertKeyPair) IssueCert(opt *CertOptions) (*CertKeyPair, error) {
+	if !ca.Cert.IsCA {
+		return nil, ErrCertNotCA
+	}
+	tmpl, key, err := leafTemplate(opt)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+	return createCert(tmpl, ca.Cert, key, ca.Key)
+}
+
+// 返回只包含该证书的CertPool,用于tls.Config的RootCAs或ClientCAs
+func (p *CertKeyPair) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(p.Cert)
+	return pool
+}
+
+// 用于tls.Config的Certificates
+func (p *CertKeyPair) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(p.CertPem, p.KeyPem)
+}
+
+// 写入证书和私钥文件,私钥权限为0600,写入方式同WriteKeyFiles
+func (p *CertKeyPair) WriteFiles(certFile, keyFile string, opt *KeyFileOptions) error {
+	if opt == nil {
+		opt = new(KeyFileOptions)
+	}
+	return writeFilesAtomic([]atomicFile{
+		{name: certFile, data: p.CertPem, perm: 0644},
+		{name: keyFile, data: p.KeyPem, perm: 0600},
+	}, opt.Overwrite)
+}
+
+// 加载证书和私钥文件,例如加载之前生成的CA继续签发证书
+func LoadCertKeyPair(certFile, keyFile string, password []byte) (*CertKeyPair, error) {
+	certPem, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certPem)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, &KeyError{Err: ErrKeyPem}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(keyPem, password)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKeyEqual(cert.PublicKey, key.Public()) {
+		return nil, errors.New("private key does not match certificate")
+	}
+	if len(password) > 0 { // 保存未加密的私钥,用于TLSCertificate
+		if keyPem, err = MarshalPrivateKeyPem(key); err != nil {
+			return nil, err
+		}
+	}
+	return &CertKeyPair{Cert: cert, Key: key, CertPem: certPem, KeyPem: keyPem}, nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+func certTemplate(opt *CertOptions, validFor time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	if opt == nil {
+		opt = new(CertOptions)
+	}
+	key := opt.Key
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, nil, err
+		}
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notBefore := opt.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now().Add(-time.Minute)
+	}
+	if opt.ValidFor > 0 {
+		validFor = opt.ValidFor
+	}
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   opt.CommonName,
+			Organization: opt.Organization,
+		},
+		DNSNames:    opt.DNSNames,
+		IPAddresses: opt.IPAddresses,
+		NotBefore:   notBefore,
+		NotAfter:    notBefore.Add(validFor),
+	}, key, nil
+}
+
+func leafTemplate(opt *CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	tmpl, key, err := certTemplate(opt, 365*24*time.Hour)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		tmpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	if opt == nil || opt.Server || !opt.Client {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if opt != nil && opt.Client {
+		tmpl.ExtKeyUsage = append(tmpl.ExtKeyUsage, x509.ExtKeyUsageClientAuth)
+	}
+	return tmpl, key, nil
+}
+
+func createCert(tmpl, parent *x509.Certificate, key, parentKey crypto.Signer) (*CertKeyPair, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyPem, err := MarshalPrivateKeyPem(key)
+	if err != nil {
+		return nil, err
+	}
+	return &CertKeyPair{
+		Cert:    cert,
+		Key:     key,
+		CertPem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPem:  keyPem,
+	}, nil
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
@@ -0,0 +1,55 @@
+package golibs
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Cert
+
+func TestCert(t *testing.T) {
+	dir := t.TempDir()
+	Convey("test cert mtls", t, func() {
+		ca, err := GenCA(&CertOptions{CommonName: "test ca"})
+		So(err, ShouldBeNil)
+		So(ca.WriteFiles(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca.key"), nil), ShouldBeNil)
+		ca, err = LoadCertKeyPair(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca.key"), nil)
+		So(err, ShouldBeNil)
+
+		server, err := ca.IssueCert(&CertOptions{CommonName: "server",
+			DNSNames: []string{"localhost"}, IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)}})
+		So(err, ShouldBeNil)
+		client, err := ca.IssueCert(&CertOptions{CommonName: "client", Client: true})
+		So(err, ShouldBeNil)
+		_, err = server.IssueCert(nil)
+		So(err, ShouldEqual, ErrCertNotCA)
+
+		serverCert, err := server.TLSCertificate()
+		So(err, ShouldBeNil)
+		clientCert, err := client.TLSCertificate()
+		So(err, ShouldBeNil)
+
+		c1, c2 := net.Pipe()
+		defer c1.Close()
+		defer c2.Close()
+		errC := make(chan error, 1)
+		go func() {
+			errC <- tls.Server(c1, &tls.Config{
+				Certificates: []tls.Certificate{serverCert},
+				ClientCAs:    ca.CertPool(),
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}).Handshake()
+		}()
+		err = tls.Client(c2, &tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      ca.CertPool(),
+			ServerName:   "localhost",
+		}).Handshake()
+		So(err, ShouldBeNil)
+		So(<-errC, ShouldBeNil)
+	})
+}
@@ -0,0 +1,30 @@
+package golibs
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// 密文的文本编码方式,base64.Encoding可以直接作为Codec使用
+type Codec interface {
+	EncodeToString(src []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+var (
+	StdCodec    Codec = base64.StdEncoding
+	URLCodec    Codec = base64.URLEncoding
+	RawStdCodec Codec = base64.RawStdEncoding // 无填充
+	RawURLCodec Codec = base64.RawURLEncoding // 无填充,适用于url和cookie
+	HexCodec    Codec = hexCodec{}
+)
+
+type hexCodec struct{}
+
+func (hexCodec) EncodeToString(src []byte) string {
+	return hex.EncodeToString(src)
+}
+
+func (hexCodec) DecodeString(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
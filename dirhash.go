@@ -0,0 +1,224 @@
+package golibs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrManifestFormat = errors.New("manifest format error")
+
+// 目录清单中的一项,Path为相对根目录的路径,使用/分隔
+type ManifestEntry struct {
+	Path string
+	Sum  string
+}
+
+// 校验结果,路径均为相对根目录的路径,按字节序排列
+type VerifyResult struct {
+	Missing    []string // 清单中有但目录中不存在
+	Extra      []string // 目录中有但清单中不存在
+	Mismatched []string // 摘要不一致
+}
+
+func (r *VerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// 使用workers个协程计算目录下所有普通文件的摘要,结果按路径排序
+// workers小于等于0时使用cpu核数,不跟随符号链接
+func HashDir(root, alg string, workers int) ([]ManifestEntry, error) {
+	paths, err := listDirFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	return hashDirFiles(root, alg, paths, workers)
+}
+
+// 计算目录摘要并与清单对比
+func VerifyDir(root, alg string, manifest io.Reader, workers int) (*VerifyResult, error) {
+	entries, err := ReadManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := listDirFiles(root)
+	if err != nil {
+		return nil, err
+	}
+
+	expect := make(map[string]string, len(entries))
+	for _, e := range entries {
+		expect[e.Path] = strings.ToLower(e.Sum)
+	}
+	var (
+		res    = new(VerifyResult)
+		exists = make(map[string]bool, len(paths))
+		check  = make([]string, 0, len(paths))
+	)
+	for _, p := range paths {
+		exists[p] = true
+		if _, ok := expect[p]; ok {
+			check = append(check, p)
+		} else {
+			res.Extra = append(res.Extra, p)
+		}
+	}
+	for _, e := range entries {
+		if !exists[e.Path] {
+			res.Missing = append(res.Missing, e.Path)
+		}
+	}
+
+	sums, err := hashDirFiles(root, alg, check, workers)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range sums {
+		if expect[e.Path] != e.Sum {
+			res.Mismatched = append(res.Mismatched, e.Path)
+		}
+	}
+	sort.Strings(res.Missing)
+	sort.Strings(res.Extra) // WalkDir按目录遍历,"a/b"在"a.txt"之前
+	return res, nil
+}
+
+// 按sha256sum格式写入清单,每行为"摘要  路径"
+// 路径包含\或换行符时与sha256sum一样转义并在行首加\
+func WriteManifest(w io.Writer, entries []ManifestEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		prefix, name := "", e.Path
+		if strings.ContainsAny(name, "\\\n") {
+			prefix = "\\"
+			name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		}
+		if _, err := fmt.Fprintf(bw, "%s%s  %s\n", prefix, e.Sum, name); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// 读取sha256sum格式清单,兼容二进制模式的"摘要 *路径"
+func ReadManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		i := strings.IndexByte(line, ' ')
+		if i <= 0 || i+2 > len(line) || (line[i+1] != ' ' && line[i+1] != '*') {
+			return nil, ErrManifestFormat
+		}
+		name := line[i+2:]
+		if escaped {
+			name = unescapeManifestPath(name)
+		}
+		entries = append(entries, ManifestEntry{Path: filepath.ToSlash(name), Sum: line[:i]})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func unescapeManifestPath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// 返回目录下所有普通文件的相对路径
+func listDirFiles(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+		return nil
+	})
+	return paths, err
+}
+
+func hashDirFiles(root, alg string, paths []string, workers int) ([]ManifestEntry, error) {
+	if _, err := NewHash(alg); err != nil {
+		return nil, err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		jobs     = make(chan int)
+		done     = make(chan struct{})
+		entries  = make([]ManifestEntry, len(paths))
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				sum, err := HashFile(alg, filepath.Join(root, filepath.FromSlash(paths[idx])))
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						close(done) // 出错后不再分发任务
+					})
+					continue
+				}
+				entries[idx] = ManifestEntry{Path: paths[idx], Sum: sum}
+			}
+		}()
+	}
+
+loop:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-done:
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
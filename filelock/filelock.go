@@ -1,49 +1,250 @@
 package filelock
 
 import (
+    "context"
     "errors"
+    "io"
     "os"
+    "sync"
+    "time"
 )
 
-var ErrFileLock = errors.New("file is lock")
+var (
+    ErrFileLock    = errors.New("file is lock")
+    ErrFileNotLock = errors.New("file is not lock")
+)
 
-// 排它锁锁住文件
+// 排它锁锁住文件,同一进程中其他*os.File持有该文件的锁时也返回ErrFileLock
 func Lock(f *os.File) error {
-    return lock(f, WriteLock)
+    return acquire(f, WriteLock)
 }
 
 // 共享锁锁住文件
 func RLock(f *os.File) error {
-    return lock(f, ReadLock)
+    return acquire(f, ReadLock)
+}
+
+// 阻塞直到获取排它锁,ctx取消或超时返回ctx.Err()
+func LockWait(ctx context.Context, f *os.File) error {
+    return lockWait(ctx, func() error { return acquire(f, WriteLock) })
+}
+
+// 阻塞直到获取共享锁,ctx取消或超时返回ctx.Err()
+func RLockWait(ctx context.Context, f *os.File) error {
+    return lockWait(ctx, func() error { return acquire(f, ReadLock) })
 }
 
-// 释放文件锁
+// 释放文件锁,加锁的文件必须在关闭前调用
 func Unlock(f *os.File) error {
-    return unlock(f)
+    return release(f)
+}
+
+/*----------------------------------------------------------------------------*/
+
+// 占用锁的信息
+type LockOwner struct {
+    Type  LockType
+    Start int64
+    Len   int64 // 0表示到文件末尾
+    Pid   int   // 无法获取时为-1,例如linux上由OFD锁占用或windows
+}
+
+// 锁住文件的[start, start+length)区域,length为0表示到文件末尾(包括以后追加的数据)
+// linux使用OFD锁,同一进程中不同的os.File之间也会互斥,关闭文件自动释放
+// 区域被锁时立即返回ErrFileLock
+func LockRange(f *os.File, lt LockType, start, length int64) error {
+    return lockRange(f, lt, start, length, false)
+}
+
+// 阻塞直到锁住区域,ctx取消或超时返回ctx.Err()
+// ctx不会取消时直接使用阻塞的系统调用,否则重试非阻塞加锁
+func LockRangeWait(ctx context.Context, f *os.File, lt LockType, start, length int64) error {
+    if ctx.Done() == nil {
+        return lockRange(f, lt, start, length, true)
+    }
+    return lockWait(ctx, func() error { return lockRange(f, lt, start, length, false) })
+}
+
+// 释放区域锁,区域需要和加锁时一致
+func UnlockRange(f *os.File, start, length int64) error {
+    return unlockRange(f, start, length)
 }
 
-type file struct {
-    File *os.File
+// 查询以lt类型锁住区域时是否冲突,不冲突时返回nil
+func QueryRange(f *os.File, lt LockType, start, length int64) (*LockOwner, error) {
+    return queryRange(f, lt, start, length)
 }
 
-// 打开文件并带上锁
-func LockOpenFile(name string, flag int, perm os.FileMode, lt lockType) (*file, error) {
+/*----------------------------------------------------------------------------*/
+
+type options struct {
+    ctx     context.Context
+    timeout time.Duration
+}
+
+type Option func(*options)
+
+// 阻塞直到获取锁或ctx取消
+func WithContext(ctx context.Context) Option {
+    return func(o *options) { o.ctx = ctx }
+}
+
+// 阻塞直到获取锁或超时,可以和WithContext一起使用
+func WithTimeout(d time.Duration) Option {
+    return func(o *options) { o.timeout = d }
+}
+
+// 带锁的文件,嵌入*os.File可直接读写,Close时释放锁
+type LockedFile struct {
+    *os.File
+
+    mu     sync.Mutex
+    lt     LockType
+    locked bool
+}
+
+var (
+    _ io.ReadWriteCloser = (*LockedFile)(nil)
+    _ io.Seeker          = (*LockedFile)(nil)
+)
+
+// 打开文件并带上锁,默认文件被锁时立即返回ErrFileLock,通过opts设置等待
+func LockOpenFile(name string, flag int, perm os.FileMode, lt LockType, opts ...Option) (*LockedFile, error) {
+    var o options
+    for _, opt := range opts {
+        opt(&o)
+    }
+
     fr, err := os.OpenFile(name, flag, perm)
     if err != nil {
         return nil, err
     }
-    if err = lock(fr, lt); err != nil {
+    if o.ctx == nil && o.timeout <= 0 {
+        err = acquire(fr, lt)
+    } else {
+        ctx := o.ctx
+        if ctx == nil {
+            ctx = context.Background()
+        }
+        if o.timeout > 0 {
+            var cancel context.CancelFunc
+            ctx, cancel = context.WithTimeout(ctx, o.timeout)
+            defer cancel()
+        }
+        err = lockWait(ctx, func() error { return acquire(fr, lt) })
+    }
+    if err != nil {
         fr.Close()
         return nil, err
     }
-    return &file{File: fr}, nil
+    return &LockedFile{File: fr, lt: lt, locked: true}, nil
+}
+
+// 是否持有锁,Unlock,Close或转换失败后为false
+func (f *LockedFile) Locked() bool {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.locked
+}
+
+// 当前锁的类型
+func (f *LockedFile) Type() LockType {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.lt
+}
+
+// 共享锁升级为排它锁,其他进程持有共享锁时返回ErrFileLock
+func (f *LockedFile) Upgrade() error {
+    return f.convert(WriteLock)
+}
+
+// 排它锁降级为共享锁
+func (f *LockedFile) Downgrade() error {
+    return f.convert(ReadLock)
+}
+
+// 释放锁但不关闭文件
+func (f *LockedFile) Unlock() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if !f.locked {
+        return ErrFileNotLock
+    }
+    f.locked = false
+    return release(f.File)
 }
 
 // 释放锁并关闭文件
-func (f *file) Close() error {
-    err := unlock(f.File)
+func (f *LockedFile) Close() error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    var err error
+    if f.locked {
+        f.locked = false
+        err = release(f.File)
+    }
     if closeErr := f.File.Close(); err == nil {
         err = closeErr
     }
     return err
 }
+
+// 转换锁类型不是原子操作,原来的锁会先释放,失败时尝试恢复原来的锁
+// 恢复也失败时不再持有锁,可以通过Locked查询
+func (f *LockedFile) convert(lt LockType) error {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if !f.locked {
+        return ErrFileNotLock
+    }
+    if f.lt == lt {
+        return nil
+    }
+
+    err := acquire(f.File, lt)
+    if err == nil {
+        f.lt = lt
+        return nil
+    }
+    _, f.locked = holding(f.File)
+    return err
+}
+
+const (
+    minWaitInterval = time.Millisecond
+    maxWaitInterval = 100 * time.Millisecond
+)
+
+// 以非阻塞方式重试加锁,间隔逐渐增大
+// 不使用阻塞的系统调用,因此ctx取消后不会残留卡在系统调用中的协程
+func lockWait(ctx context.Context, try func() error) error {
+    var (
+        timer    *time.Timer
+        interval = minWaitInterval
+    )
+    for {
+        err := try()
+        if err != ErrFileLock {
+            if timer != nil {
+                timer.Stop()
+            }
+            return err
+        }
+
+        if timer == nil {
+            timer = time.NewTimer(interval)
+        } else {
+            timer.Reset(interval)
+        }
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-timer.C:
+        }
+        if interval *= 2; interval > maxWaitInterval {
+            interval = maxWaitInterval
+        }
+    }
+}
@@ -1,18 +1,25 @@
 package filelock
 
 import (
+    "bytes"
+    "io"
     "os"
+    "strconv"
+    "strings"
     "syscall"
+
+    "golang.org/x/sys/unix"
 )
 
-type lockType int
+// 锁类型,ReadLock为共享锁,WriteLock为排它锁
+type LockType int
 
 const (
-    ReadLock  lockType = syscall.LOCK_SH
-    WriteLock lockType = syscall.LOCK_EX
+    ReadLock  LockType = syscall.LOCK_SH
+    WriteLock LockType = syscall.LOCK_EX
 )
 
-func lock(f *os.File, lt lockType) error {
+func lock(f *os.File, lt LockType) error {
     err := syscall.Flock(int(f.Fd()), int(lt)|syscall.LOCK_NB)
     if err != nil {
         if errNo, ok := err.(syscall.Errno); ok && errNo == 0xb {
@@ -25,3 +32,97 @@ func lock(f *os.File, lt lockType) error {
 func unlock(f *os.File) error {
     return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
 }
+
+func rangeLockType(lt LockType) int16 {
+    if lt == ReadLock {
+        return unix.F_RDLCK
+    }
+    return unix.F_WRLCK
+}
+
+func fcntlRange(f *os.File, cmd int, typ int16, start, length int64) (*unix.Flock_t, error) {
+    lk := &unix.Flock_t{
+        Type:   typ,
+        Whence: io.SeekStart,
+        Start:  start,
+        Len:    length,
+    }
+    err := unix.FcntlFlock(f.Fd(), cmd, lk)
+    if err == unix.EAGAIN || err == unix.EACCES {
+        return nil, ErrFileLock
+    }
+    return lk, err
+}
+
+func lockRange(f *os.File, lt LockType, start, length int64, wait bool) error {
+    cmd := unix.F_OFD_SETLK
+    if wait {
+        cmd = unix.F_OFD_SETLKW
+    }
+    _, err := fcntlRange(f, cmd, rangeLockType(lt), start, length)
+    return err
+}
+
+func unlockRange(f *os.File, start, length int64) error {
+    _, err := fcntlRange(f, unix.F_OFD_SETLK, unix.F_UNLCK, start, length)
+    return err
+}
+
+func queryRange(f *os.File, lt LockType, start, length int64) (*LockOwner, error) {
+    lk, err := fcntlRange(f, unix.F_OFD_GETLK, rangeLockType(lt), start, length)
+    if err != nil || lk.Type == unix.F_UNLCK {
+        return nil, err
+    }
+
+    owner := &LockOwner{Type: WriteLock, Start: lk.Start, Len: lk.Len, Pid: int(lk.Pid)}
+    if lk.Type == unix.F_RDLCK {
+        owner.Type = ReadLock
+    }
+    return owner, nil // 由OFD锁占用时Pid为-1,由传统fcntl锁占用时为进程id
+}
+
+// flock可以直接转换已持有的锁
+func relock(f *os.File, lt LockType) error {
+    return lock(f, lt)
+}
+
+func fileId(f *os.File) (fileKey, error) {
+    var st syscall.Stat_t
+    if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+        return fileKey{}, err
+    }
+    return fileKey{dev: uint64(st.Dev), ino: st.Ino}, nil
+}
+
+// 发送信号0检查进程是否存在,没有权限时进程也存在
+func processAlive(pid int) bool {
+    err := syscall.Kill(pid, 0)
+    return err == nil || err == syscall.EPERM
+}
+
+// 每次开机随机生成,比启动时间可靠,不受修改系统时间影响
+func bootId() string {
+    data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+    if err != nil {
+        return ""
+    }
+    return strings.TrimSpace(string(data))
+}
+
+// 进程启动时间,为开机后的clock ticks,见proc(5)中/proc/pid/stat的starttime
+func processStartTime(pid int) (int64, error) {
+    data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+    if err != nil {
+        return 0, err
+    }
+    // 进程名可能包含空格和括号,从最后一个')'后开始解析,第一个字段为state(第3项)
+    i := bytes.LastIndexByte(data, ')')
+    if i < 0 {
+        return 0, ErrLockFileFormat
+    }
+    fields := strings.Fields(string(data[i+1:]))
+    if len(fields) < 20 {
+        return 0, ErrLockFileFormat
+    }
+    return strconv.ParseInt(fields[19], 10, 64) // starttime为第22项
+}
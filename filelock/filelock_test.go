@@ -0,0 +1,321 @@
+package filelock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Lock
+
+func TestLockWait(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "lock")
+	Convey("test lock wait", t, func() {
+		f1, err := LockOpenFile(name, os.O_CREATE|os.O_RDWR, 0644, WriteLock)
+		So(err, ShouldBeNil)
+
+		f2, err := os.OpenFile(name, os.O_RDWR, 0644)
+		So(err, ShouldBeNil)
+		defer f2.Close()
+		So(Lock(f2), ShouldEqual, ErrFileLock)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		So(RLockWait(ctx, f2), ShouldEqual, context.DeadlineExceeded)
+
+		_, err = LockOpenFile(name, os.O_RDWR, 0644, WriteLock, WithTimeout(20*time.Millisecond))
+		So(err, ShouldEqual, context.DeadlineExceeded)
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			f1.Close()
+		}()
+		So(LockWait(context.Background(), f2), ShouldBeNil)
+		So(Unlock(f2), ShouldBeNil)
+	})
+}
+
+func TestLockRange(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "range")
+	Convey("test lock range", t, func() {
+		f1, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+		So(err, ShouldBeNil)
+		defer f1.Close()
+		f2, err := os.OpenFile(name, os.O_RDWR, 0644)
+		So(err, ShouldBeNil)
+		defer f2.Close()
+
+		So(LockRange(f1, WriteLock, 0, 100), ShouldBeNil)
+		So(LockRange(f2, WriteLock, 100, 100), ShouldBeNil) // 不重叠的区域
+		So(LockRange(f2, ReadLock, 50, 10), ShouldEqual, ErrFileLock)
+
+		owner, err := QueryRange(f2, ReadLock, 50, 10)
+		So(err, ShouldBeNil)
+		So(owner, ShouldNotBeNil)
+		So(owner.Type, ShouldEqual, WriteLock)
+		owner, err = QueryRange(f2, ReadLock, 200, 10)
+		So(err, ShouldBeNil)
+		So(owner, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		So(LockRangeWait(ctx, f2, WriteLock, 0, 10), ShouldEqual, context.DeadlineExceeded)
+
+		So(UnlockRange(f1, 0, 100), ShouldBeNil)
+		So(LockRangeWait(context.Background(), f2, ReadLock, 0, 10), ShouldBeNil)
+		So(LockRange(f1, ReadLock, 0, 10), ShouldBeNil) // 共享锁
+	})
+}
+
+func TestLockedFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "locked")
+	Convey("test locked file", t, func() {
+		f1, err := LockOpenFile(name, os.O_CREATE|os.O_RDWR, 0644, ReadLock)
+		So(err, ShouldBeNil)
+		So(f1.Locked(), ShouldBeTrue)
+		So(f1.Type(), ShouldEqual, ReadLock)
+
+		_, err = f1.Write([]byte("hello"))
+		So(err, ShouldBeNil)
+		_, err = f1.Seek(0, io.SeekStart)
+		So(err, ShouldBeNil)
+		data, err := io.ReadAll(f1)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "hello")
+
+		f2, err := LockOpenFile(name, os.O_RDONLY, 0644, ReadLock)
+		So(err, ShouldBeNil)
+		So(f1.Upgrade(), ShouldEqual, ErrFileLock) // f2持有共享锁
+		So(f1.Locked(), ShouldBeTrue)
+		So(f1.Type(), ShouldEqual, ReadLock)
+		So(f2.Close(), ShouldBeNil)
+
+		So(f1.Upgrade(), ShouldBeNil)
+		So(f1.Type(), ShouldEqual, WriteLock)
+		_, err = LockOpenFile(name, os.O_RDONLY, 0644, ReadLock)
+		So(err, ShouldEqual, ErrFileLock)
+		So(f1.Downgrade(), ShouldBeNil)
+
+		So(f1.Unlock(), ShouldBeNil)
+		So(f1.Locked(), ShouldBeFalse)
+		So(f1.Upgrade(), ShouldEqual, ErrFileNotLock)
+		So(f1.Close(), ShouldBeNil)
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "registry")
+	Convey("test in-process registry", t, func() {
+		f1, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+		So(err, ShouldBeNil)
+		defer f1.Close()
+		f2, err := os.OpenFile(name, os.O_RDWR, 0644)
+		So(err, ShouldBeNil)
+		defer f2.Close()
+
+		So(RLock(f1), ShouldBeNil)
+		So(RLock(f2), ShouldBeNil)
+		So(Lock(f1), ShouldEqual, ErrFileLock) // f2持有共享锁
+		So(Unlock(f2), ShouldBeNil)
+
+		So(Lock(f1), ShouldBeNil)
+		So(Lock(f1), ShouldBeNil) // 同一句柄重复加锁
+		So(RLock(f2), ShouldEqual, ErrFileLock)
+		lt, ok := holding(f1)
+		So(ok, ShouldBeTrue)
+		So(lt, ShouldEqual, WriteLock)
+
+		So(Unlock(f1), ShouldBeNil) // 不计数,一次即释放
+		_, ok = holding(f1)
+		So(ok, ShouldBeFalse)
+		So(Lock(f2), ShouldBeNil)
+		So(Unlock(f2), ShouldBeNil)
+		So(registry.files, ShouldBeEmpty)
+		So(registry.keys, ShouldBeEmpty)
+
+	})
+}
+
+// go test . -race -run Registry
+func TestRegistryConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	Convey("test concurrent lock and close", t, func() {
+		var (
+			wg     sync.WaitGroup
+			writer int32
+			failed int32
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					name, shared := filepath.Join(dir, "shared"), j%2 == 1
+					if !shared { // 一半使用不同的文件
+						name = filepath.Join(dir, "file"+strconv.Itoa(i*50+j))
+					}
+					lt := ReadLock
+					if j%5 == i%5 {
+						lt = WriteLock
+					}
+					f, err := LockOpenFile(name, os.O_CREATE|os.O_RDWR, 0644, lt)
+					if err == ErrFileLock {
+						continue
+					}
+					if err != nil {
+						atomic.StoreInt32(&failed, 1)
+						return
+					}
+					if shared && lt == WriteLock {
+						if atomic.AddInt32(&writer, 1) != 1 {
+							atomic.StoreInt32(&failed, 1) // 同时有两个排它锁
+						}
+						atomic.AddInt32(&writer, -1)
+					}
+					if f.Close() != nil {
+						atomic.StoreInt32(&failed, 1)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		So(failed, ShouldEqual, 0)
+
+		// 关闭后不能留下登记
+		registry.Lock()
+		defer registry.Unlock()
+		So(registry.files, ShouldBeEmpty)
+		So(registry.keys, ShouldBeEmpty)
+	})
+}
+
+func TestLockFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data")
+	Convey("test lock file", t, func() {
+		l1, l2 := NewLockFile(name), NewLockFile(name)
+		So(l1.Lock(), ShouldBeNil)
+		So(l2.Lock(), ShouldEqual, ErrFileLock)
+
+		owner, err := l2.Owner()
+		So(err, ShouldBeNil)
+		So(owner.Pid, ShouldEqual, os.Getpid())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		So(l2.LockWait(ctx), ShouldEqual, context.DeadlineExceeded)
+		So(l1.Unlock(), ShouldBeNil)
+		So(l1.Unlock(), ShouldEqual, ErrFileNotLock)
+
+		writeOwner := func(info LockInfo) {
+			data, _ := json.Marshal(&info)
+			So(os.WriteFile(l1.Path, data, 0644), ShouldBeNil)
+		}
+
+		// 有boot id时以boot id为准,启动时间不一致(例如修改过系统时间)且进程存在时不能接管
+		stale := *owner
+		if stale.BootId != "" {
+			stale.BootTime -= 3600
+			writeOwner(stale)
+			So(l2.Lock(), ShouldEqual, ErrFileLock)
+		}
+
+		// 没有boot id时比较启动时间,误差范围内不能接管
+		stale = *owner
+		stale.BootId = ""
+		stale.BootTime -= bootTimeSlack / 2
+		writeOwner(stale)
+		So(l2.Lock(), ShouldEqual, ErrFileLock)
+
+		stale.BootTime -= 3600
+		writeOwner(stale)
+		So(l2.Lock(), ShouldBeNil)
+		So(l2.Unlock(), ShouldBeNil)
+
+		// 上次开机前创建的锁文件视为过期
+		if bootId() != "" {
+			stale = *owner
+			stale.BootId = "other-boot"
+			writeOwner(stale)
+			So(l2.Lock(), ShouldBeNil)
+			So(l1.Lock(), ShouldEqual, ErrFileLock)
+			So(l2.Unlock(), ShouldBeNil)
+		}
+
+		// pid被复用,启动时间不一致
+		stale = *owner
+		stale.StartTime++
+		writeOwner(stale)
+		So(l2.Lock(), ShouldBeNil)
+		So(l2.Unlock(), ShouldBeNil)
+
+		// 同一主机上持有者进程不存在
+		_, err = l2.Owner()
+		So(errors.Is(err, os.ErrNotExist), ShouldBeTrue)
+		stale = *owner
+		stale.Pid = 1 << 30
+		writeOwner(stale)
+		So(l1.Lock(), ShouldBeNil)
+		So(l1.Unlock(), ShouldBeNil)
+
+		// 移走的文件已被替换时恢复原来的文件
+		writeOwner(stale)
+		data, err := os.ReadFile(l1.Path)
+		So(err, ShouldBeNil)
+		So(removeStale(l1.Path, []byte("other"), "token"), ShouldEqual, ErrFileLock)
+		again, err := os.ReadFile(l1.Path)
+		So(err, ShouldBeNil)
+		So(again, ShouldResemble, data)
+		So(removeStale(l1.Path, data, "token"), ShouldBeNil)
+		So(errors.Is(removeStale(l1.Path, data, "token"), os.ErrNotExist), ShouldBeTrue)
+
+		// 多个进程同时接管,只有一个成功,且不会留下guard和重命名的文件
+		// 奇数轮预先留下接管过程中退出的进程的guard
+		for round := 0; round < 20; round++ {
+			writeOwner(stale)
+			if round%2 == 1 {
+				data, _ := json.Marshal(&stale)
+				So(os.WriteFile(l1.Path+".takeover", data, 0644), ShouldBeNil)
+			}
+			var (
+				wg      sync.WaitGroup
+				success int32
+				lockers = make([]*LockFile, 8)
+			)
+			for i := range lockers {
+				lockers[i] = NewLockFile(name)
+				wg.Add(1)
+				go func(l *LockFile) {
+					defer wg.Done()
+					if l.Lock() == nil {
+						atomic.AddInt32(&success, 1)
+					}
+				}(lockers[i])
+			}
+			wg.Wait()
+			So(success, ShouldEqual, 1)
+			for _, l := range lockers {
+				if l.info != nil {
+					So(l.Unlock(), ShouldBeNil)
+				}
+			}
+			names, err := filepath.Glob(l1.Path + ".*")
+			So(err, ShouldBeNil)
+			So(names, ShouldBeEmpty)
+		}
+
+		var locker Locker = NewFileLocker(name)
+		So(locker.Lock(), ShouldBeNil)
+		So(NewFileLocker(name).Lock(), ShouldEqual, ErrFileLock)
+		So(locker.Unlock(), ShouldBeNil)
+	})
+}
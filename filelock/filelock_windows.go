@@ -6,11 +6,12 @@ import (
     "unsafe"
 )
 
-type lockType uint32
+// 锁类型,ReadLock为共享锁,WriteLock为排它锁
+type LockType uint32
 
 const (
-    ReadLock  lockType = 0
-    WriteLock lockType = 3 // LOCKFILE_FAIL_IMMEDIATELY | LOCKFILE_EXCLUSIVE_LOCK
+    ReadLock  LockType = 1 // LOCKFILE_FAIL_IMMEDIATELY, 与linux一致不阻塞
+    WriteLock LockType = 3 // LOCKFILE_FAIL_IMMEDIATELY | LOCKFILE_EXCLUSIVE_LOCK
 
     reserved = 0
     allBytes = ^uint32(0)
@@ -22,11 +23,61 @@ var (
     procUnlockFileEx = modKernel32.NewProc("UnlockFileEx")
 )
 
-func lock(f *os.File, lt lockType) error {
-    ol := new(syscall.Overlapped)
+func lock(f *os.File, lt LockType) error {
+    return lockFileEx(f, uint32(lt), 0, 0)
+}
+
+func unlock(f *os.File) error {
+    return unlockFileEx(f, 0, 0)
+}
+
+// LockFileEx不能转换已持有的锁,先释放再加锁
+func relock(f *os.File, lt LockType) error {
+    if err := unlock(f); err != nil {
+        return err
+    }
+    return lock(f, lt)
+}
+
+// windows的锁为强制锁,其他句柄读写被锁区域会失败
+func lockRange(f *os.File, lt LockType, start, length int64, wait bool) error {
+    flags := uint32(lt)
+    if wait {
+        flags &^= 1 // 去掉LOCKFILE_FAIL_IMMEDIATELY
+    }
+    return lockFileEx(f, flags, start, length)
+}
+
+func unlockRange(f *os.File, start, length int64) error {
+    return unlockFileEx(f, start, length)
+}
+
+// windows没有查询接口,尝试加锁后立即释放,无法获取占用者信息
+func queryRange(f *os.File, lt LockType, start, length int64) (*LockOwner, error) {
+    err := lockFileEx(f, uint32(lt), start, length)
+    if err == ErrFileLock {
+        return &LockOwner{Type: lt, Start: start, Len: length, Pid: -1}, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return nil, unlockFileEx(f, start, length)
+}
+
+// length为0时锁住从start开始的所有字节
+func rangeOverlapped(start, length int64) (*syscall.Overlapped, uint32, uint32) {
+    ol := &syscall.Overlapped{Offset: uint32(start), OffsetHigh: uint32(start >> 32)}
+    if length == 0 {
+        return ol, allBytes, allBytes
+    }
+    return ol, uint32(length), uint32(length >> 32)
+}
+
+func lockFileEx(f *os.File, flags uint32, start, length int64) error {
+    ol, low, high := rangeOverlapped(start, length)
     r1, _, e1 := syscall.Syscall6(procLockFileEx.Addr(), 6, f.Fd(),
-        uintptr(lt), uintptr(reserved), uintptr(allBytes),
-        uintptr(allBytes), uintptr(unsafe.Pointer(ol)))
+        uintptr(flags), uintptr(reserved), uintptr(low),
+        uintptr(high), uintptr(unsafe.Pointer(ol)))
     if r1 == 0 {
         if e1 != 0 {
             if e1 == 0x21 { // 找到文件被锁错误码,返回自定义错误
@@ -39,10 +90,10 @@ func lock(f *os.File, lt lockType) error {
     return nil
 }
 
-func unlock(f *os.File) error {
-    ol := new(syscall.Overlapped)
+func unlockFileEx(f *os.File, start, length int64) error {
+    ol, low, high := rangeOverlapped(start, length)
     r1, _, e1 := syscall.Syscall6(procUnlockFileEx.Addr(), 5, f.Fd(),
-        uintptr(reserved), uintptr(allBytes), uintptr(allBytes),
+        uintptr(reserved), uintptr(low), uintptr(high),
         uintptr(unsafe.Pointer(ol)), 0)
     if r1 == 0 {
         if e1 != 0 {
@@ -52,3 +103,50 @@ func unlock(f *os.File) error {
     }
     return nil
 }
+
+func fileId(f *os.File) (fileKey, error) {
+    var fi syscall.ByHandleFileInformation
+    if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &fi); err != nil {
+        return fileKey{}, err
+    }
+    return fileKey{
+        dev: uint64(fi.VolumeSerialNumber),
+        ino: uint64(fi.FileIndexHigh)<<32 | uint64(fi.FileIndexLow),
+    }, nil
+}
+
+const processQueryLimitedInformation = 0x1000
+
+// 进程存在且未退出
+func processAlive(pid int) bool {
+    const stillActive = 259
+    h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+    if err != nil {
+        return err == syscall.ERROR_ACCESS_DENIED
+    }
+    defer syscall.CloseHandle(h)
+    var code uint32
+    if err = syscall.GetExitCodeProcess(h, &code); err != nil {
+        return true
+    }
+    return code == stillActive
+}
+
+// windows没有boot id,只能使用启动时间
+func bootId() string {
+    return ""
+}
+
+// 进程创建时间,FILETIME转换为纳秒
+func processStartTime(pid int) (int64, error) {
+    h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+    if err != nil {
+        return 0, err
+    }
+    defer syscall.CloseHandle(h)
+    var creation, exit, kernel, user syscall.Filetime
+    if err = syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+        return 0, err
+    }
+    return creation.Nanoseconds(), nil
+}
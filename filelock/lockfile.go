@@ -0,0 +1,362 @@
+package filelock
+
+import (
+    "bytes"
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    "github.com/jan-bar/golibs/timer"
+)
+
+var ErrLockFileFormat = errors.New("lock file format error")
+
+// 通用的锁接口,NewFileLocker使用flock,NewLockFile使用锁文件
+type Locker interface {
+    Lock() error                        // 被占用时立即返回ErrFileLock
+    LockWait(ctx context.Context) error // 阻塞直到获取锁,ctx取消或超时返回ctx.Err()
+    Unlock() error
+}
+
+var (
+    _ Locker = (*fileLocker)(nil)
+    _ Locker = (*LockFile)(nil)
+)
+
+/*----------------------------------------------------------------------------*/
+
+type fileLocker struct {
+    name string
+    mu   sync.Mutex
+    f    *LockedFile
+}
+
+// 对name文件加排它锁,文件不存在时创建
+func NewFileLocker(name string) Locker {
+    return &fileLocker{name: name}
+}
+
+func (l *fileLocker) Lock() error {
+    return l.lock()
+}
+
+func (l *fileLocker) LockWait(ctx context.Context) error {
+    return l.lock(WithContext(ctx))
+}
+
+func (l *fileLocker) lock(opts ...Option) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f != nil {
+        return nil
+    }
+    f, err := LockOpenFile(l.name, os.O_CREATE|os.O_RDWR, 0644, WriteLock, opts...)
+    if err != nil {
+        return err
+    }
+    l.f = f
+    return nil
+}
+
+func (l *fileLocker) Unlock() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.f == nil {
+        return ErrFileNotLock
+    }
+    err := l.f.Close()
+    l.f = nil
+    return err
+}
+
+/*----------------------------------------------------------------------------*/
+
+/*
+锁文件协议,用于flock不可靠的NFS或共享卷
+    加锁: 以O_EXCL创建<name>.lock,写入LockInfo
+    解锁: 确认锁文件仍属于自己后删除
+    过期: 同一主机上持有者进程不存在,pid被其他进程复用(启动时间不一致)或属于上次开机
+          上次开机优先比较boot id(linux),没有boot id时比较系统启动时间,允许bootTimeSlack的误差
+          其他主机持有时无法检查进程,修改时间超过StaleAge视为过期
+    接管: 以同样的方式创建<name>.lock.takeover,同一时间只有一个进程可以接管
+          过期的文件(锁文件或guard)不按路径删除,而是先重命名为唯一的名称,
+          重命名后的内容与判断过期时相同才删除,否则说明已被其他进程替换,以不覆盖的方式恢复
+*/
+type LockFile struct {
+    Path string // 锁文件路径
+
+    // 其他主机持有的锁超过该时间未更新视为过期,为0时不过期
+    // 持有锁的时间较长时需要定时调用Refresh
+    StaleAge time.Duration
+
+    mu   sync.Mutex
+    info *LockInfo // 持有锁时不为nil
+}
+
+// 锁文件内容
+type LockInfo struct {
+    Pid      int    `json:"pid"`
+    Hostname string `json:"hostname"`
+    BootId   string `json:"boot_id,omitempty"` // linux的/proc/sys/kernel/random/boot_id
+    // 系统启动时间戳,timer.GetSysUpTime,没有boot id时用于判断是否为上次开机
+    BootTime int64 `json:"boot_time"`
+    // 进程启动时间,linux为开机后的clock ticks,windows为创建时间,用于判断pid是否被复用
+    StartTime int64  `json:"start_time"`
+    Token     string `json:"token"` // 区分同一进程中的不同LockFile
+}
+
+const (
+    // 刚创建还未写入内容的锁文件,超过该时间仍无法解析视为过期
+    lockFileGrace = 10 * time.Second
+
+    // 启动时间由当前时间减去开机时长得到,修改系统时间或时钟同步会产生误差
+    // 相差超过该值才认为是不同的开机
+    bootTimeSlack = 60
+)
+
+// 使用name.lock作为锁文件
+func NewLockFile(name string) *LockFile {
+    return &LockFile{Path: name + ".lock"}
+}
+
+func (l *LockFile) Lock() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.info != nil {
+        return nil
+    }
+
+    info, err := newLockInfo()
+    if err != nil {
+        return err
+    }
+    if err = createLockFile(l.Path, info); err == ErrFileLock {
+        if !l.takeover(info) {
+            return ErrFileLock
+        }
+        err = nil
+    }
+    if err == nil {
+        l.info = info
+    }
+    return err
+}
+
+func (l *LockFile) LockWait(ctx context.Context) error {
+    return lockWait(ctx, l.Lock)
+}
+
+// 删除锁文件,锁文件已被其他进程接管时返回ErrFileNotLock
+func (l *LockFile) Unlock() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.info == nil {
+        return ErrFileNotLock
+    }
+    token := l.info.Token
+    l.info = nil
+    return removeLockFile(l.Path, token)
+}
+
+// 更新锁文件修改时间,避免被其他主机判断为过期
+func (l *LockFile) Refresh() error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if l.info == nil {
+        return ErrFileNotLock
+    }
+    now := time.Now()
+    return os.Chtimes(l.Path, now, now)
+}
+
+// 读取当前持有者信息,锁文件不存在时返回os.ErrNotExist
+func (l *LockFile) Owner() (*LockInfo, error) {
+    owner, _, err := readLockInfo(l.Path)
+    if err == nil && owner == nil {
+        err = ErrLockFileFormat
+    }
+    return owner, err
+}
+
+// 锁文件过期时接管,返回true表示已经以info重新创建锁文件
+func (l *LockFile) takeover(info *LockInfo) bool {
+    owner, data, err := readLockInfo(l.Path)
+    if err != nil {
+        // 持有者刚好释放
+        return errors.Is(err, os.ErrNotExist) && createLockFile(l.Path, info) == nil
+    }
+    if !l.stale(l.Path, owner) {
+        return false
+    }
+
+    guard := l.Path + ".takeover"
+    if !l.lockGuard(guard, info) {
+        return false
+    }
+    defer removeLockFile(guard, info.Token)
+
+    // 持有guard时其他进程不能接管,过期的持有者不会解锁,普通加锁因锁文件存在而失败
+    // removeStale再次确认内容,即使guard被同时持有也只有一个进程能移走过期的锁文件
+    if err = removeStale(l.Path, data, info.Token); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return false
+    }
+    return createLockFile(l.Path, info) == nil
+}
+
+// guard的持有者在接管过程中退出时,guard按同样的规则过期
+func (l *LockFile) lockGuard(guard string, info *LockInfo) bool {
+    err := createLockFile(guard, info)
+    if err != ErrFileLock {
+        return err == nil
+    }
+    owner, data, err := readLockInfo(guard)
+    if err != nil || !l.stale(guard, owner) {
+        return false
+    }
+    if err = removeStale(guard, data, info.Token); err != nil && !errors.Is(err, os.ErrNotExist) {
+        return false
+    }
+    return createLockFile(guard, info) == nil
+}
+
+func (l *LockFile) stale(path string, owner *LockInfo) bool {
+    fi, err := os.Stat(path)
+    if err != nil {
+        return false
+    }
+    if owner == nil { // 内容无法解析
+        return time.Since(fi.ModTime()) > lockFileGrace
+    }
+
+    if host, _ := os.Hostname(); owner.Hostname != host {
+        return l.StaleAge > 0 && time.Since(fi.ModTime()) > l.StaleAge
+    }
+    if id := bootId(); id != "" && owner.BootId != "" {
+        if owner.BootId != id {
+            return true // 上次开机前创建的锁文件
+        }
+    } else if owner.BootTime != 0 {
+        if d := timer.GetSysUpTime() - owner.BootTime; d > bootTimeSlack || d < -bootTimeSlack {
+            return true
+        }
+    }
+    if !processAlive(owner.Pid) {
+        return true
+    }
+    if owner.StartTime != 0 {
+        if st, err := processStartTime(owner.Pid); err == nil && st != owner.StartTime {
+            return true // pid已被其他进程复用
+        }
+    }
+    return false
+}
+
+/*----------------------------------------------------------------------------*/
+
+func createLockFile(path string, info *LockInfo) error {
+    data, err := json.Marshal(info)
+    if err != nil {
+        return err
+    }
+    f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+    if err != nil {
+        if errors.Is(err, os.ErrExist) {
+            return ErrFileLock
+        }
+        return err
+    }
+    _, err = f.Write(data)
+    if err == nil {
+        err = f.Sync()
+    }
+    if closeErr := f.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(path)
+    }
+    return err
+}
+
+// 确认锁文件属于token后删除
+func removeLockFile(path, token string) error {
+    owner, _, err := readLockInfo(path)
+    if err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return ErrFileNotLock
+        }
+        return err
+    }
+    if owner == nil || owner.Token != token {
+        return ErrFileNotLock
+    }
+    return os.Remove(path)
+}
+
+// 删除判断为过期的文件,data为判断过期时读取的内容
+// 先重命名为唯一的名称,同一个文件只有一个进程能重命名成功,再确认内容没有变化
+// 内容不同说明path已被其他进程替换为新的文件,恢复到path并返回ErrFileLock
+// path已不存在时返回os.ErrNotExist
+func removeStale(path string, data []byte, token string) error {
+    tmp := path + "." + token + ".stale"
+    if err := os.Rename(path, tmp); err != nil {
+        return err
+    }
+    moved, err := os.ReadFile(tmp)
+    if err == nil && bytes.Equal(moved, data) {
+        return os.Remove(tmp)
+    }
+
+    // 硬链接不会覆盖期间新创建的文件,不支持硬链接时退化为检查后重命名
+    if err = os.Link(tmp, path); err != nil && !errors.Is(err, os.ErrExist) {
+        if _, err = os.Lstat(path); errors.Is(err, os.ErrNotExist) && os.Rename(tmp, path) == nil {
+            return ErrFileLock
+        }
+    }
+    os.Remove(tmp)
+    return ErrFileLock
+}
+
+func newLockInfo() (*LockInfo, error) {
+    host, err := os.Hostname()
+    if err != nil {
+        return nil, err
+    }
+    pid := os.Getpid()
+    start, _ := processStartTime(pid) // 获取失败时为0,不检查pid复用
+    return &LockInfo{
+        Pid:       pid,
+        Hostname:  host,
+        BootId:    bootId(),
+        BootTime:  timer.GetSysUpTime(),
+        StartTime: start,
+        Token:     randToken(),
+    }, nil
+}
+
+// 内容无法解析时LockInfo为nil,同时返回原始内容用于接管时比较
+func readLockInfo(path string) (*LockInfo, []byte, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, nil, err
+    }
+    info := new(LockInfo)
+    if json.Unmarshal(data, info) != nil || info.Pid <= 0 {
+        return nil, data, nil
+    }
+    return info, data, nil
+}
+
+func randToken() string {
+    b := make([]byte, 8)
+    if _, err := rand.Read(b); err != nil {
+        return strconv.FormatInt(time.Now().UnixNano(), 16)
+    }
+    return hex.EncodeToString(b)
+}
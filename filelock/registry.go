@@ -0,0 +1,104 @@
+package filelock
+
+import (
+    "os"
+    "sync"
+)
+
+/*
+进程内的锁登记表,按设备号和inode(windows为卷序列号和文件索引)区分文件
+同一进程中多次打开同一文件时,不同*os.File之间的加锁行为与跨进程一致:
+    共享锁之间不冲突,排它锁与其他任何锁冲突,冲突时返回ErrFileLock
+同一*os.File重复加锁:
+    类型相同时直接返回成功,不计数,一次Unlock即释放
+    类型不同时转换锁类型,同Upgrade/Downgrade
+加锁的*os.File必须先Unlock再关闭,或使用LockedFile.Close
+直接关闭时系统虽然释放了锁,但登记仍然存在,会一直阻止进程内其他句柄加锁
+登记表不访问其他句柄的fd,因此可以在多个goroutine中并发加锁和关闭
+*/
+type fileKey struct {
+    dev uint64
+    ino uint64
+}
+
+type lockRegistry struct {
+    sync.Mutex
+    files map[fileKey]map[*os.File]LockType // 文件 -> 持有锁的句柄及锁类型
+    keys  map[*os.File]fileKey
+}
+
+var registry = &lockRegistry{
+    files: make(map[fileKey]map[*os.File]LockType),
+    keys:  make(map[*os.File]fileKey),
+}
+
+// 先检查进程内冲突,再加系统锁
+func acquire(f *os.File, lt LockType) error {
+    key, err := fileId(f)
+    if err != nil {
+        return err
+    }
+
+    registry.Lock()
+    defer registry.Unlock()
+    holders := registry.files[key]
+    old, held := holders[f]
+    if held && old == lt {
+        return nil
+    }
+    for h, t := range holders {
+        if h != f && (lt == WriteLock || t == WriteLock) {
+            return ErrFileLock
+        }
+    }
+
+    if !held {
+        if err = lock(f, lt); err != nil {
+            return err
+        }
+    } else if err = relock(f, lt); err != nil {
+        if lock(f, old) != nil { // 转换失败时尝试恢复原来的锁
+            registry.remove(f, key)
+        }
+        return err
+    }
+
+    if holders == nil {
+        holders = make(map[*os.File]LockType)
+        registry.files[key] = holders
+    }
+    holders[f] = lt
+    registry.keys[f] = key
+    return nil
+}
+
+// 释放系统锁并删除登记
+func release(f *os.File) error {
+    registry.Lock()
+    defer registry.Unlock()
+    if key, ok := registry.keys[f]; ok {
+        registry.remove(f, key)
+    }
+    return unlock(f)
+}
+
+// 是否持有锁,以及锁的类型
+func holding(f *os.File) (LockType, bool) {
+    registry.Lock()
+    defer registry.Unlock()
+    key, ok := registry.keys[f]
+    if !ok {
+        return 0, false
+    }
+    lt, ok := registry.files[key][f]
+    return lt, ok
+}
+
+// 调用者需要持有锁
+func (r *lockRegistry) remove(f *os.File, key fileKey) {
+    delete(r.files[key], f)
+    delete(r.keys, f)
+    if len(r.files[key]) == 0 {
+        delete(r.files, key)
+    }
+}
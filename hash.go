@@ -0,0 +1,169 @@
+package golibs
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+// 支持的摘要算法名称,不区分大小写
+const (
+	HashMD5        = "md5"
+	HashSHA1       = "sha1"
+	HashSHA256     = "sha256"
+	HashSHA512     = "sha512"
+	HashBLAKE2b256 = "blake2b-256"
+	HashBLAKE2b512 = "blake2b-512"
+	HashCRC32      = "crc32"  // IEEE
+	HashXXHash     = "xxhash" // xxh64
+)
+
+var ErrHashAlg = errors.New("hash algorithm not supported")
+
+// 每次读取的数据量,也是检查取消和回调进度的间隔
+const hashChunkSize = 1024 * 1024
+
+// 进度回调,done为已处理的字节数,total为总字节数,未知时为-1
+type HashProgress func(done, total int64)
+
+var hashFuncs = map[string]func() hash.Hash{
+	HashMD5:    md5.New,
+	HashSHA1:   sha1.New,
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+	HashBLAKE2b256: func() hash.Hash {
+		h, _ := blake2b.New256(nil)
+		return h
+	},
+	HashBLAKE2b512: func() hash.Hash {
+		h, _ := blake2b.New512(nil)
+		return h
+	},
+	HashCRC32:  func() hash.Hash { return crc32.NewIEEE() },
+	HashXXHash: func() hash.Hash { return xxhash.New() },
+}
+
+// 根据算法名称创建hash.Hash
+func NewHash(alg string) (hash.Hash, error) {
+	fn, ok := hashFuncs[strings.ToLower(alg)]
+	if !ok {
+		return nil, ErrHashAlg
+	}
+	return fn(), nil
+}
+
+// 计算数据摘要,返回hex字符串
+func HashBytes(alg string, data []byte) (string, error) {
+	h, err := NewHash(alg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 读取r直到EOF并计算摘要,返回hex字符串
+func HashReader(alg string, r io.Reader) (string, error) {
+	res, err := HashReaderMulti(r, alg)
+	if err != nil {
+		return "", err
+	}
+	return res[alg], nil
+}
+
+// 计算文件摘要,返回hex字符串
+func HashFile(alg, path string) (string, error) {
+	res, err := HashFileMulti(path, alg)
+	if err != nil {
+		return "", err
+	}
+	return res[alg], nil
+}
+
+// 只读取一次r,同时计算多个摘要,返回算法名称到hex字符串的映射
+func HashReaderMulti(r io.Reader, algs ...string) (map[string]string, error) {
+	return HashReaderContext(context.Background(), r, -1, nil, algs...)
+}
+
+// 同HashReaderMulti,每读取一块数据检查ctx是否取消并回调progress,progress可为nil
+// total为r的总字节数,只用于回调,未知时传-1
+// 注意: 阻塞在r.Read时无法取消,读取文件时不存在该问题
+func HashReaderContext(ctx context.Context, r io.Reader, total int64,
+	progress HashProgress, algs ...string) (map[string]string, error) {
+	if len(algs) == 0 {
+		return nil, ErrHashAlg
+	}
+	hs := make([]hash.Hash, len(algs))
+	ws := make([]io.Writer, len(algs))
+	for i, alg := range algs {
+		h, err := NewHash(alg)
+		if err != nil {
+			return nil, err
+		}
+		hs[i], ws[i] = h, h
+	}
+
+	var (
+		w    = io.MultiWriter(ws...)
+		buf  = make([]byte, hashChunkSize)
+		done int64
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n]) // hash.Hash的Write不会返回错误
+			done += int64(n)
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(map[string]string, len(algs))
+	for i, alg := range algs {
+		res[alg] = hex.EncodeToString(hs[i].Sum(nil))
+	}
+	return res, nil
+}
+
+// 同HashFileMulti,支持取消和进度回调,回调的total为文件大小
+func HashFileContext(ctx context.Context, path string,
+	progress HashProgress, algs ...string) (map[string]string, error) {
+	fr, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+
+	total := int64(-1)
+	if fi, err := fr.Stat(); err == nil && fi.Mode().IsRegular() {
+		total = fi.Size()
+	}
+	return HashReaderContext(ctx, fr, total, progress, algs...)
+}
+
+// 只读取一次文件,同时计算多个摘要
+func HashFileMulti(path string, algs ...string) (map[string]string, error) {
+	return HashFileContext(context.Background(), path, nil, algs...)
+}
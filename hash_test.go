@@ -0,0 +1,143 @@
+package golibs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Hash
+
+func TestHash(t *testing.T) {
+	Convey("test hash", t, func() {
+		expect := map[string]string{
+			HashMD5:        "5eb63bbbe01eeed093cb22bb8f5acdc3",
+			HashSHA1:       "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed",
+			HashSHA256:     "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			HashBLAKE2b256: "256c83b297114d201b30179f3f0ef0cace9783622da5974326b436178aeef610",
+			HashCRC32:      "0d4a1185",
+			HashXXHash:     "45ab6734b21e6968",
+		}
+		name := filepath.Join(t.TempDir(), "hello.txt")
+		So(os.WriteFile(name, []byte("hello world"), 0644), ShouldBeNil)
+
+		algs := make([]string, 0, len(expect))
+		for alg, sum := range expect {
+			res, err := HashBytes(strings.ToUpper(alg), []byte("hello world"))
+			So(err, ShouldBeNil)
+			So(res, ShouldEqual, sum)
+			res, err = HashReader(alg, strings.NewReader("hello world"))
+			So(err, ShouldBeNil)
+			So(res, ShouldEqual, sum)
+			algs = append(algs, alg)
+		}
+		res, err := HashFileMulti(name, algs...)
+		So(err, ShouldBeNil)
+		So(res, ShouldResemble, expect)
+
+		sum, err := Md5sum(name, true)
+		So(err, ShouldBeNil)
+		So(sum, ShouldEqual, expect[HashMD5])
+		sum, err = Md5sum("hello world", false)
+		So(err, ShouldBeNil)
+		So(sum, ShouldEqual, expect[HashMD5])
+
+		_, err = HashBytes("md4", nil)
+		So(err, ShouldEqual, ErrHashAlg)
+	})
+}
+
+func TestHashDir(t *testing.T) {
+	root := t.TempDir()
+	Convey("test hash dir", t, func() {
+		for name, data := range map[string]string{
+			"a.txt":     "a",
+			"b/c.txt":   "c",
+			"b/d/e.txt": "e",
+			"f\\g.txt":  "g",
+		} {
+			name = filepath.Join(root, filepath.FromSlash(name))
+			So(os.MkdirAll(filepath.Dir(name), 0755), ShouldBeNil)
+			So(os.WriteFile(name, []byte(data), 0644), ShouldBeNil)
+		}
+
+		entries, err := HashDir(root, HashSHA256, 2)
+		So(err, ShouldBeNil)
+		So(len(entries), ShouldEqual, 4)
+		So(entries[0].Path, ShouldEqual, "a.txt")
+
+		var manifest strings.Builder
+		So(WriteManifest(&manifest, entries), ShouldBeNil)
+		res, err := VerifyDir(root, HashSHA256, strings.NewReader(manifest.String()), 2)
+		So(err, ShouldBeNil)
+		So(res.OK(), ShouldBeTrue)
+
+		So(os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0644), ShouldBeNil)
+		So(os.Remove(filepath.Join(root, "b", "c.txt")), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(root, "h.txt"), []byte("h"), 0644), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(root, "b", "i.txt"), []byte("i"), 0644), ShouldBeNil)
+		res, err = VerifyDir(root, HashSHA256, strings.NewReader(manifest.String()), 0)
+		So(err, ShouldBeNil)
+		So(res.OK(), ShouldBeFalse)
+		So(res.Mismatched, ShouldResemble, []string{"a.txt"})
+		So(res.Missing, ShouldResemble, []string{"b/c.txt"})
+		So(res.Extra, ShouldResemble, []string{"b.txt", "b/i.txt", "h.txt"})
+	})
+}
+
+func TestHmac(t *testing.T) {
+	Convey("test hmac", t, func() {
+		key := []byte("key")
+		data := "The quick brown fox jumps over the lazy dog"
+		mac, err := HmacString(HashSHA256, key, data, HexCodec)
+		So(err, ShouldBeNil)
+		So(mac, ShouldEqual, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8")
+		So(HmacVerifyString(HashSHA256, key, data, mac, HexCodec), ShouldBeTrue)
+		So(HmacVerifyString(HashSHA256, key, data+".", mac, HexCodec), ShouldBeFalse)
+		So(HmacVerifyString(HashSHA256, key, data, "zz", HexCodec), ShouldBeFalse)
+
+		mac, err = HmacString(HashSHA512, key, data, StdCodec)
+		So(err, ShouldBeNil)
+		byt, _ := StdCodec.DecodeString(mac)
+		ok, err := HmacVerifyReader(HashSHA512, key, strings.NewReader(data), byt)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+
+		_, err = HmacSum(HashCRC32, key, nil)
+		So(err, ShouldEqual, ErrHashAlg)
+		So(ConstantTimeEqual("token", "token"), ShouldBeTrue)
+		So(ConstantTimeEqual("token", "toke"), ShouldBeFalse)
+	})
+}
+
+func TestHashContext(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "big.bin")
+	Convey("test hash context", t, func() {
+		data := bytes.Repeat([]byte{'a'}, 3*hashChunkSize+10)
+		So(os.WriteFile(name, data, 0644), ShouldBeNil)
+
+		var calls, last int64
+		res, err := HashFileContext(context.Background(), name, func(done, total int64) {
+			calls++
+			last = done
+			So(total, ShouldEqual, len(data))
+		}, HashSHA256)
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 4)
+		So(last, ShouldEqual, len(data))
+		sum, _ := HashBytes(HashSHA256, data)
+		So(res[HashSHA256], ShouldEqual, sum)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err = HashFileContext(ctx, name, func(done, total int64) {
+			cancel() // 处理第一块后取消
+		}, HashSHA256)
+		So(err, ShouldEqual, context.Canceled)
+	})
+}
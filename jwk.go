@@ -0,0 +1,339 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+)
+
+var ErrJwk = errors.New("jwk error")
+
+// RFC 7517 JSON Web Key,支持RSA,EC(P-256/P-384/P-521)和OKP(Ed25519)
+// 二进制字段均为base64url(无填充)编码
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+
+	// 私钥字段
+	D  string `json:"d,omitempty"`
+	P  string `json:"p,omitempty"`
+	Q  string `json:"q,omitempty"`
+	Dp string `json:"dp,omitempty"`
+	Dq string `json:"dq,omitempty"`
+	Qi string `json:"qi,omitempty"`
+}
+
+// 由公钥或私钥生成JWK,kid为RFC 7638 thumbprint
+func NewJWK(key interface{}) (*JWK, error) {
+	var j *JWK
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		j = &JWK{Kty: "RSA", N: b64(k.N.Bytes()), E: b64(big.NewInt(int64(k.E)).Bytes())}
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, ErrJwk
+		}
+		j, _ = NewJWK(&k.PublicKey)
+		p, q := k.Primes[0], k.Primes[1]
+		j.D, j.P, j.Q = b64(k.D.Bytes()), b64(p.Bytes()), b64(q.Bytes())
+		dp, dq, qi := k.Precomputed.Dp, k.Precomputed.Dq, k.Precomputed.Qinv
+		if dp == nil || dq == nil || qi == nil {
+			// 私钥可能来自共享的缓存,不调用Precompute修改私钥
+			one := big.NewInt(1)
+			dp = new(big.Int).Mod(k.D, new(big.Int).Sub(p, one))
+			dq = new(big.Int).Mod(k.D, new(big.Int).Sub(q, one))
+			if qi = new(big.Int).ModInverse(q, p); qi == nil {
+				return nil, ErrJwk
+			}
+		}
+		j.Dp, j.Dq, j.Qi = b64(dp.Bytes()), b64(dq.Bytes()), b64(qi.Bytes())
+	case *ecdsa.PublicKey:
+		crv, size := jwkCurveName(k.Curve)
+		if crv == "" {
+			return nil, ErrJwk
+		}
+		j = &JWK{Kty: "EC", Crv: crv,
+			X: b64(k.X.FillBytes(make([]byte, size))),
+			Y: b64(k.Y.FillBytes(make([]byte, size)))}
+	case *ecdsa.PrivateKey:
+		var err error
+		if j, err = NewJWK(&k.PublicKey); err != nil {
+			return nil, err
+		}
+		j.D = b64(k.D.FillBytes(make([]byte, (k.Curve.Params().BitSize+7)/8)))
+	case ed25519.PublicKey:
+		j = &JWK{Kty: "OKP", Crv: "Ed25519", X: b64(k)}
+	case ed25519.PrivateKey:
+		j = &JWK{Kty: "OKP", Crv: "Ed25519", X: b64(k.Public().(ed25519.PublicKey)), D: b64(k.Seed())}
+	default:
+		return nil, ErrJwk
+	}
+
+	var err error
+	if j.Kid, err = j.Thumbprint(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// 由pem格式公钥或私钥生成JWK
+func NewJWKFromPem(data, password []byte) (*JWK, error) {
+	if key, err := ParsePublicKey(data); err == nil {
+		return NewJWK(key)
+	}
+	key, err := ParsePrivateKey(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWK(key)
+}
+
+// RFC 7638 thumbprint,SHA-256后使用base64url编码
+func (j *JWK) Thumbprint() (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	// 只包含必需字段,且按字典序排列
+	switch j.Kty {
+	case "RSA":
+		data, err = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N})
+	case "EC":
+		data, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{j.Crv, j.Kty, j.X, j.Y})
+	case "OKP":
+		data, err = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X})
+	default:
+		return "", ErrJwk
+	}
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64(sum[:]), nil
+}
+
+// 是否包含私钥
+func (j *JWK) IsPrivate() bool {
+	return j.D != ""
+}
+
+// 去掉私钥字段,用于发布
+func (j *JWK) Public() *JWK {
+	pub := *j
+	pub.D, pub.P, pub.Q, pub.Dp, pub.Dq, pub.Qi = "", "", "", "", "", ""
+	return &pub
+}
+
+// 返回*rsa.PublicKey, *ecdsa.PublicKey或ed25519.PublicKey
+func (j *JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, e := unb64Int(j.N), unb64Int(j.E)
+		if n == nil || e == nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, ErrJwk
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve := jwkCurve(j.Crv)
+		x, y := unb64Int(j.X), unb64Int(j.Y)
+		if curve == nil || x == nil || y == nil {
+			return nil, ErrJwk
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil { // 校验点在曲线上
+			return nil, ErrJwk
+		}
+		return pub, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, ErrJwk
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, ErrJwk
+}
+
+// 返回*rsa.PrivateKey, *ecdsa.PrivateKey或ed25519.PrivateKey
+func (j *JWK) PrivateKey() (crypto.Signer, error) {
+	if !j.IsPrivate() {
+		return nil, ErrJwk
+	}
+	pub, err := j.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	d := unb64Int(j.D)
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		p, q := unb64Int(j.P), unb64Int(j.Q)
+		if d == nil || p == nil || q == nil {
+			return nil, ErrJwk
+		}
+		priv := &rsa.PrivateKey{PublicKey: *k, D: d, Primes: []*big.Int{p, q}}
+		if err = priv.Validate(); err != nil {
+			return nil, ErrJwk
+		}
+		priv.Precompute()
+		return priv, nil
+	case *ecdsa.PublicKey:
+		if d == nil {
+			return nil, ErrJwk
+		}
+		priv := &ecdsa.PrivateKey{PublicKey: *k, D: d}
+		ecdhKey, err := priv.ECDH() // 校验私钥和公钥匹配
+		if err != nil {
+			return nil, ErrJwk
+		}
+		if pubECDH, _ := k.ECDH(); !ecdhKey.PublicKey().Equal(pubECDH) {
+			return nil, ErrJwk
+		}
+		return priv, nil
+	case ed25519.PublicKey:
+		seed, err := base64.RawURLEncoding.DecodeString(j.D)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, ErrJwk
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		if !k.Equal(priv.Public()) {
+			return nil, ErrJwk
+		}
+		return priv, nil
+	}
+	return nil, ErrJwk
+}
+
+/*----------------------------------------------------------------------------*/
+
+// RFC 7517 JWK Set
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// 从json文件加载JWKS
+func LoadJWKS(path string) (*JWKS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := new(JWKS)
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 写入json文件,包含私钥时文件权限为0600
+func (s *JWKS) WriteFile(path string, overwrite bool) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	perm := os.FileMode(0644)
+	for _, k := range s.Keys {
+		if k.IsPrivate() {
+			perm = 0600
+			break
+		}
+	}
+	return writeFilesAtomic([]atomicFile{{name: path, data: data, perm: perm}}, overwrite)
+}
+
+// 按kid查找,找不到返回nil
+func (s *JWKS) Lookup(kid string) *JWK {
+	for _, k := range s.Keys {
+		if k.Kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// 只包含公钥的JWKS
+func (s *JWKS) Public() *JWKS {
+	pub := &JWKS{Keys: make([]*JWK, len(s.Keys))}
+	for i, k := range s.Keys {
+		pub.Keys[i] = k.Public()
+	}
+	return pub
+}
+
+// 发布公钥,可直接注册为/.well-known/jwks.json的处理函数
+func (s *JWKS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(s.Public())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+/*----------------------------------------------------------------------------*/
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64Int(s string) *big.Int {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func jwkCurveName(curve elliptic.Curve) (string, int) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", 32
+	case elliptic.P384():
+		return "P-384", 48
+	case elliptic.P521():
+		return "P-521", 66
+	}
+	return "", 0
+}
+
+func jwkCurve(crv string) elliptic.Curve {
+	switch crv {
+	case "P-256":
+		return elliptic.P256()
+	case "P-384":
+		return elliptic.P384()
+	case "P-521":
+		return elliptic.P521()
+	}
+	return nil
+}
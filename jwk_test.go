@@ -0,0 +1,72 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Jwk
+
+func TestJwk(t *testing.T) {
+	Convey("test jwk", t, func() {
+		// RFC 7638 3.1
+		j := &JWK{Kty: "RSA", E: "AQAB", N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}
+		kid, err := j.Thumbprint()
+		So(err, ShouldBeNil)
+		So(kid, ShouldEqual, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+		s := new(JWKS)
+		for _, key := range []interface {
+			Equal(x crypto.PrivateKey) bool
+		}{rsaKey, ecKey, edKey} {
+			j, err = NewJWK(key)
+			So(err, ShouldBeNil)
+			So(j.IsPrivate(), ShouldBeTrue)
+			priv, err := j.PrivateKey()
+			So(err, ShouldBeNil)
+			So(key.Equal(priv), ShouldBeTrue)
+			s.Keys = append(s.Keys, j)
+		}
+
+		// 未预计算的私钥,NewJWK不能修改私钥
+		bare := &rsa.PrivateKey{PublicKey: rsaKey.PublicKey, D: rsaKey.D, Primes: rsaKey.Primes}
+		j, err = NewJWK(bare)
+		So(err, ShouldBeNil)
+		So(bare.Precomputed.Dp, ShouldBeNil)
+		So(j.Dp, ShouldEqual, b64(rsaKey.Precomputed.Dp.Bytes()))
+		So(j.Dq, ShouldEqual, b64(rsaKey.Precomputed.Dq.Bytes()))
+		So(j.Qi, ShouldEqual, b64(rsaKey.Precomputed.Qinv.Bytes()))
+
+		name := filepath.Join(t.TempDir(), "jwks.json")
+		So(s.WriteFile(name, false), ShouldBeNil)
+		s, err = LoadJWKS(name)
+		So(err, ShouldBeNil)
+		So(len(s.Keys), ShouldEqual, 3)
+		So(s.Lookup(s.Keys[1].Kid).Kty, ShouldEqual, "EC")
+
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+		pub := new(JWKS)
+		So(json.Unmarshal(rec.Body.Bytes(), pub), ShouldBeNil)
+		for i, k := range pub.Keys {
+			So(k.IsPrivate(), ShouldBeFalse)
+			So(k.P, ShouldBeEmpty)
+			So(k.Kid, ShouldEqual, s.Keys[i].Kid)
+			_, err = k.PublicKey()
+			So(err, ShouldBeNil)
+		}
+	})
+}
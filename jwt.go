@@ -0,0 +1,348 @@
+package golibs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 支持的JWS算法
+const (
+	JwtRS256 = "RS256"
+	JwtPS256 = "PS256"
+	JwtES256 = "ES256"
+	JwtEdDSA = "EdDSA"
+	JwtHS256 = "HS256"
+)
+
+var (
+	ErrJwtFormat    = errors.New("jwt format error")
+	ErrJwtAlg       = errors.New("jwt alg not allowed or not match key")
+	ErrJwtKey       = errors.New("jwt key not found")
+	ErrJwtSignature = errors.New("jwt signature error")
+	ErrJwtExpired   = errors.New("jwt expired")
+	ErrJwtNotBefore = errors.New("jwt not valid yet")
+	ErrJwtIssuedAt  = errors.New("jwt issued in the future")
+	ErrJwtIssuer    = errors.New("jwt issuer error")
+	ErrJwtAudience  = errors.New("jwt audience error")
+)
+
+type JwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// 解析后的claims,数字类型为json.Number
+type JwtClaims map[string]interface{}
+
+/*
+签名生成jwt,claims可以是结构体或map,kid为空时不写入头部
+key类型需要和alg匹配
+
+	RS256/PS256: *rsa.PrivateKey
+	ES256:       *ecdsa.PrivateKey(P-256)
+	EdDSA:       ed25519.PrivateKey
+	HS256:       []byte
+*/
+func JwtSign(claims interface{}, alg string, key interface{}, kid string) (string, error) {
+	header, err := json.Marshal(&JwtHeader{Alg: alg, Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signing := b64(header) + "." + b64(payload)
+	sig, err := jwsSign(alg, key, []byte(signing))
+	if err != nil {
+		return "", err
+	}
+	return signing + "." + b64(sig), nil
+}
+
+// 根据jwt头部查找验签密钥,可以返回公钥,私钥或HS256使用的[]byte
+type JwtKeyFunc func(header *JwtHeader) (interface{}, error)
+
+type JwtOptions struct {
+	Keys JwtKeyFunc // 必须设置
+	Algs []string   // 允许的算法,为空时允许所有支持的算法
+
+	Issuer   string        // 不为空时校验iss
+	Audience string        // 不为空时校验aud
+	Leeway   time.Duration // 校验exp/nbf/iat时允许的时钟误差
+	Now      func() time.Time
+
+	RequireExp bool // 为true时没有exp返回ErrJwtExpired
+}
+
+// 使用JWKS按kid查找公钥
+func JwtKeysFromJWKS(s *JWKS) JwtKeyFunc {
+	return func(header *JwtHeader) (interface{}, error) {
+		j := s.Lookup(header.Kid)
+		if j == nil {
+			return nil, ErrJwtKey
+		}
+		return j.PublicKey()
+	}
+}
+
+// 使用pem格式公钥或证书按kid查找,复用ParsePublicKey的缓存
+func JwtKeysFromPem(keys map[string][]byte) JwtKeyFunc {
+	return func(header *JwtHeader) (interface{}, error) {
+		data, ok := keys[header.Kid]
+		if !ok {
+			return nil, ErrJwtKey
+		}
+		return ParsePublicKey(data)
+	}
+}
+
+// 验签并校验exp/nbf/iat/iss/aud,返回claims
+func JwtVerify(token string, opt *JwtOptions) (JwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJwtFormat
+	}
+	headerData, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrJwtFormat
+	}
+	header := new(JwtHeader)
+	if err = json.Unmarshal(headerData, header); err != nil {
+		return nil, ErrJwtFormat
+	}
+	if !jwtAlgAllowed(header.Alg, opt.Algs) {
+		return nil, ErrJwtAlg
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJwtFormat
+	}
+
+	if opt.Keys == nil {
+		return nil, ErrJwtKey
+	}
+	key, err := opt.Keys(header)
+	if err != nil {
+		return nil, err
+	}
+	if err = jwsVerify(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrJwtFormat
+	}
+	claims := make(JwtClaims)
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err = dec.Decode(&claims); err != nil {
+		return nil, ErrJwtFormat
+	}
+	if err = claims.validate(opt); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+// 读取数字类型claim,例如exp
+func (c JwtClaims) Time(name string) (time.Time, bool) {
+	n, ok := c[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	if i, err := n.Int64(); err == nil {
+		return time.Unix(i, 0), true
+	}
+	f, err := n.Float64() // 允许带小数的秒数
+	if err != nil || math.IsNaN(f) || math.Abs(f) > 1<<62 {
+		return time.Time{}, false
+	}
+	sec := math.Floor(f)
+	return time.Unix(int64(sec), int64((f-sec)*float64(time.Second))), true
+}
+
+// 读取字符串类型claim,例如sub
+func (c JwtClaims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// aud可以是字符串或字符串数组
+func (c JwtClaims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		res := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func (c JwtClaims) validate(opt *JwtOptions) error {
+	now := time.Now()
+	if opt.Now != nil {
+		now = opt.Now()
+	}
+
+	if exp, ok := c.Time("exp"); ok {
+		if !now.Before(exp.Add(opt.Leeway)) {
+			return ErrJwtExpired
+		}
+	} else if _, has := c["exp"]; has || opt.RequireExp {
+		return ErrJwtExpired
+	}
+	if nbf, ok := c.Time("nbf"); ok {
+		if now.Add(opt.Leeway).Before(nbf) {
+			return ErrJwtNotBefore
+		}
+	} else if _, has := c["nbf"]; has {
+		return ErrJwtNotBefore
+	}
+	if iat, ok := c.Time("iat"); ok {
+		if now.Add(opt.Leeway).Before(iat) {
+			return ErrJwtIssuedAt
+		}
+	} else if _, has := c["iat"]; has {
+		return ErrJwtIssuedAt
+	}
+
+	if opt.Issuer != "" && c.String("iss") != opt.Issuer {
+		return ErrJwtIssuer
+	}
+	if opt.Audience != "" {
+		for _, aud := range c.Audience() {
+			if aud == opt.Audience {
+				return nil
+			}
+		}
+		return ErrJwtAudience
+	}
+	return nil
+}
+
+func jwtAlgAllowed(alg string, algs []string) bool {
+	switch alg {
+	case JwtRS256, JwtPS256, JwtES256, JwtEdDSA, JwtHS256:
+	default:
+		return false
+	}
+	if len(algs) == 0 {
+		return true
+	}
+	for _, a := range algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+/*----------------------------------------------------------------------------*/
+
+func jwsSign(alg string, key interface{}, signing []byte) ([]byte, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		switch alg {
+		case JwtRS256:
+			return (&RsaSigner{Key: k, Hash: crypto.SHA256}).Sign(signing)
+		case JwtPS256:
+			return (&RsaSigner{Key: k, Hash: crypto.SHA256, Type: RsaPSS}).Sign(signing)
+		}
+	case *ecdsa.PrivateKey:
+		if alg == JwtES256 && k.Curve == elliptic.P256() {
+			// JWS使用r|s定长格式,而不是ASN.1格式
+			digest := sha256.Sum256(signing)
+			r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			sig := make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+			return sig, nil
+		}
+	case ed25519.PrivateKey:
+		if alg == JwtEdDSA {
+			return Ed25519Signer{Key: k}.Sign(signing)
+		}
+	case []byte:
+		if alg == JwtHS256 {
+			return HmacSum(HashSHA256, k, signing)
+		}
+	}
+	return nil, ErrJwtAlg
+}
+
+func jwsVerify(alg string, key interface{}, signing, sig []byte) error {
+	if s, ok := key.(crypto.Signer); ok {
+		key = s.Public() // 允许直接使用私钥验签
+	}
+
+	var err error
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case JwtRS256:
+			err = (&RsaVerifier{Key: k, Hash: crypto.SHA256}).Verify(signing, sig)
+		case JwtPS256:
+			err = (&RsaVerifier{Key: k, Hash: crypto.SHA256, Type: RsaPSS}).Verify(signing, sig)
+		default:
+			return ErrJwtAlg
+		}
+	case *ecdsa.PublicKey:
+		if alg != JwtES256 || k.Curve != elliptic.P256() {
+			return ErrJwtAlg
+		}
+		if len(sig) != 64 {
+			return ErrJwtSignature
+		}
+		digest := sha256.Sum256(signing)
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return ErrJwtSignature
+		}
+	case ed25519.PublicKey:
+		if alg != JwtEdDSA {
+			return ErrJwtAlg
+		}
+		err = Ed25519Verifier{Key: k}.Verify(signing, sig)
+	case []byte:
+		if alg != JwtHS256 {
+			return ErrJwtAlg
+		}
+		if !HmacVerify(HashSHA256, k, signing, sig) {
+			return ErrJwtSignature
+		}
+	default:
+		return ErrJwtAlg
+	}
+	if err != nil {
+		return ErrJwtSignature
+	}
+	return nil
+}
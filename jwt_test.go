@@ -0,0 +1,100 @@
+package golibs
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Jwt
+
+func TestJwt(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	Convey("test jwt", t, func() {
+		now := time.Now()
+		claims := map[string]interface{}{
+			"iss": "golibs",
+			"aud": []string{"api", "web"},
+			"sub": "user",
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+
+		s := new(JWKS)
+		for _, key := range []interface{}{rsaKey, ecKey, edKey} {
+			j, err := NewJWK(key)
+			So(err, ShouldBeNil)
+			s.Keys = append(s.Keys, j.Public())
+		}
+		opt := &JwtOptions{Keys: JwtKeysFromJWKS(s), Issuer: "golibs", Audience: "api"}
+		for _, v := range []struct {
+			alg string
+			key interface{}
+			kid string
+		}{
+			{JwtRS256, rsaKey, s.Keys[0].Kid},
+			{JwtPS256, rsaKey, s.Keys[0].Kid},
+			{JwtES256, ecKey, s.Keys[1].Kid},
+			{JwtEdDSA, edKey, s.Keys[2].Kid},
+		} {
+			token, err := JwtSign(claims, v.alg, v.key, v.kid)
+			So(err, ShouldBeNil)
+			c, err := JwtVerify(token, opt)
+			So(err, ShouldBeNil)
+			So(c.String("sub"), ShouldEqual, "user")
+
+			// 篡改签名,修改解码后的字节,避免只改到base64末尾被忽略的位
+			i := strings.LastIndexByte(token, '.')
+			sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+			So(err, ShouldBeNil)
+			sig[0] ^= 1
+			_, err = JwtVerify(token[:i+1]+b64(sig), opt)
+			So(err, ShouldEqual, ErrJwtSignature)
+		}
+
+		hsKey := []byte("secret")
+		token, err := JwtSign(claims, JwtHS256, hsKey, "")
+		So(err, ShouldBeNil)
+		hsOpt := &JwtOptions{Keys: func(*JwtHeader) (interface{}, error) { return hsKey, nil },
+			Algs: []string{JwtHS256}}
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldBeNil)
+
+		// HS256不能使用公钥验签,防止算法混淆
+		_, err = JwtVerify(token, &JwtOptions{Keys: func(*JwtHeader) (interface{}, error) {
+			return &rsaKey.PublicKey, nil
+		}})
+		So(err, ShouldEqual, ErrJwtAlg)
+		_, err = JwtVerify(token, &JwtOptions{Keys: hsOpt.Keys, Algs: []string{JwtRS256}})
+		So(err, ShouldEqual, ErrJwtAlg)
+
+		hsOpt.Now = func() time.Time { return now.Add(2 * time.Minute) }
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldEqual, ErrJwtExpired)
+		hsOpt.Leeway = 2 * time.Minute
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldBeNil)
+		hsOpt.Now = func() time.Time { return now.Add(-time.Minute) }
+		hsOpt.Leeway = 0
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldEqual, ErrJwtNotBefore)
+
+		hsOpt.Now, hsOpt.Audience = nil, "other"
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldEqual, ErrJwtAudience)
+		hsOpt.Audience, hsOpt.Issuer = "", "other"
+		_, err = JwtVerify(token, hsOpt)
+		So(err, ShouldEqual, ErrJwtIssuer)
+	})
+}
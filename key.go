@@ -0,0 +1,248 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrKeyPem      = errors.New("key pem error")          // 找不到pem格式的密钥
+	ErrKeyType     = errors.New("key type not supported") // 不支持的pem类型或密钥算法
+	ErrKeyNotRsa   = errors.New("key is not rsa")
+	ErrKeyPassword = errors.New("key password error") // 加密的私钥未提供口令或口令错误
+)
+
+// 解析密钥失败时返回,可通过errors.Is判断具体原因
+type KeyError struct {
+	Type string // pem块类型
+	Err  error
+}
+
+func (e *KeyError) Error() string {
+	if e.Type == "" {
+		return e.Err.Error()
+	}
+	return e.Type + ": " + e.Err.Error()
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+/*----------------------------------------------------------------------------*/
+
+// 缓存解析后的密钥,避免每次加解密都重新解析pem
+const keyCacheSize = 64
+
+var keyCache = struct {
+	sync.RWMutex
+	rec map[[sha256.Size]byte]interface{}
+}{
+	rec: make(map[[sha256.Size]byte]interface{}, keyCacheSize),
+}
+
+// 各字段带长度前缀,避免不同的data和password拼接后相同
+func keyCacheId(private bool, data, password []byte) [sha256.Size]byte {
+	h := sha256.New()
+	if private {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+	var n [8]byte
+	for _, b := range [][]byte{data, password} {
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
+
+func keyCacheLoad(id [sha256.Size]byte) interface{} {
+	keyCache.RLock()
+	defer keyCache.RUnlock()
+	return keyCache.rec[id]
+}
+
+func keyCacheStore(id [sha256.Size]byte, key interface{}) {
+	keyCache.Lock()
+	if len(keyCache.rec) >= keyCacheSize {
+		keyCache.rec = make(map[[sha256.Size]byte]interface{}, keyCacheSize)
+	}
+	keyCache.rec[id] = key
+	keyCache.Unlock()
+}
+
+/*----------------------------------------------------------------------------*/
+
+/*
+解析pem格式私钥,支持以下类型,password只在私钥被加密时使用
+
+	RSA PRIVATE KEY       PKCS#1,支持openssl传统加密格式(Proc-Type: 4,ENCRYPTED)
+	EC PRIVATE KEY        SEC 1,支持openssl传统加密格式
+	PRIVATE KEY           PKCS#8
+	ENCRYPTED PRIVATE KEY PKCS#8加密格式,支持PBES2(PBKDF2/scrypt + AES-CBC)
+
+返回*rsa.PrivateKey, *ecdsa.PrivateKey或ed25519.PrivateKey,结果会被缓存,调用者不要修改
+*/
+func ParsePrivateKey(data, password []byte) (crypto.Signer, error) {
+	id := keyCacheId(true, data, password)
+	if key := keyCacheLoad(id); key != nil {
+		return key.(crypto.Signer), nil
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, &KeyError{Err: ErrKeyPem}
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+		default:
+			continue // 跳过EC PARAMETERS等非私钥块
+		}
+		key, err := parsePrivateKeyBlock(block, password)
+		if err != nil {
+			return nil, &KeyError{Type: block.Type, Err: err}
+		}
+
+		switch key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		default:
+			return nil, &KeyError{Type: block.Type, Err: ErrKeyType}
+		}
+		keyCacheStore(id, key)
+		return key.(crypto.Signer), nil
+	}
+}
+
+/*
+解析pem格式公钥,支持以下类型
+
+	PUBLIC KEY     PKIX
+	RSA PUBLIC KEY PKCS#1
+	CERTIFICATE    X.509证书,返回证书中的公钥
+
+返回*rsa.PublicKey, *ecdsa.PublicKey或ed25519.PublicKey,结果会被缓存,调用者不要修改
+*/
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	id := keyCacheId(false, data, nil)
+	if key := keyCacheLoad(id); key != nil {
+		return key, nil
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, &KeyError{Err: ErrKeyPem}
+		}
+
+		var (
+			key interface{}
+			err error
+		)
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, &KeyError{Type: block.Type, Err: err}
+		}
+
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, &KeyError{Type: block.Type, Err: ErrKeyType}
+		}
+		keyCacheStore(id, key)
+		return key, nil
+	}
+}
+
+func parsePrivateKeyBlock(block *pem.Block, password []byte) (interface{}, error) {
+	der, encrypted := block.Bytes, false
+	if x509.IsEncryptedPEMBlock(block) { // openssl传统加密格式
+		if len(password) == 0 {
+			return nil, ErrKeyPassword
+		}
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, password); err != nil {
+			return nil, ErrKeyPassword
+		}
+		encrypted = true
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	default: // ENCRYPTED PRIVATE KEY
+		if len(password) == 0 {
+			return nil, ErrKeyPassword
+		}
+		if der, err = decryptPKCS8(der, password); err != nil {
+			return nil, err
+		}
+		encrypted = true
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	}
+	if err != nil && encrypted {
+		return nil, ErrKeyPassword // 解密后的数据无法解析,一般是口令错误
+	}
+	return key, err
+}
+
+// 解析rsa私钥,不是rsa密钥时返回ErrKeyNotRsa
+func ParseRsaPrivateKey(data, password []byte) (*rsa.PrivateKey, error) {
+	key, err := ParsePrivateKey(data, password)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, &KeyError{Err: ErrKeyNotRsa}
+	}
+	return priv, nil
+}
+
+// 解析rsa公钥,不是rsa密钥时返回ErrKeyNotRsa
+func ParseRsaPublicKey(data []byte) (*rsa.PublicKey, error) {
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, &KeyError{Err: ErrKeyNotRsa}
+	}
+	return pub, nil
+}
@@ -0,0 +1,282 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var ErrKeyFileExists = errors.New("key file already exists")
+
+type KeyFileOptions struct {
+	Overwrite bool   // 为true时覆盖已存在的文件,否则返回ErrKeyFileExists
+	SSHPublic string // 不为空时额外写入OpenSSH格式(authorized_keys)公钥
+}
+
+// 生成Ed25519密钥,私钥为PKCS#8格式,公钥为PKIX格式
+func GenEd25519Key(private, public string) error {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(privateKey, private, public)
+}
+
+// 在内存中生成rsa密钥,返回pem格式私钥和公钥
+func GenRsaKeyPem(bits int) (privPem, pubPem []byte, err error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return marshalKeyPair(privateKey)
+}
+
+// 在内存中生成Ed25519密钥,返回pem格式私钥和公钥
+func GenEd25519KeyPem() (privPem, pubPem []byte, err error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return marshalKeyPair(privateKey)
+}
+
+// 在内存中生成ECDSA密钥,返回pem格式私钥和公钥
+func GenEcdsaKeyPem(curve elliptic.Curve) (privPem, pubPem []byte, err error) {
+	if _, err = ecdsaHash(curve); err != nil {
+		return nil, nil, err
+	}
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return marshalKeyPair(privateKey)
+}
+
+// 生成ECDSA密钥,curve支持elliptic.P256(),P384(),P521()
+// 私钥为SEC 1格式,公钥为PKIX格式
+func GenEcdsaKey(curve elliptic.Curve, private, public string) error {
+	if _, err := ecdsaHash(curve); err != nil {
+		return err
+	}
+	privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(privateKey, private, public)
+}
+
+/*
+私钥编码为pem格式
+
+	*rsa.PrivateKey:   RSA PRIVATE KEY(PKCS#1)
+	*ecdsa.PrivateKey: EC PRIVATE KEY(SEC 1)
+	ed25519.PrivateKey: PRIVATE KEY(PKCS#8)
+*/
+func MarshalPrivateKeyPem(key crypto.Signer) ([]byte, error) {
+	block := new(pem.Block)
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		block.Type, block.Bytes = "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(k)
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		block.Type, block.Bytes = "EC PRIVATE KEY", der
+	case ed25519.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		block.Type, block.Bytes = "PRIVATE KEY", der
+	default:
+		return nil, &KeyError{Err: ErrKeyType}
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// 公钥编码为PKIX格式的pem
+func MarshalPublicKeyPem(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func marshalKeyPair(key crypto.Signer) (privPem, pubPem []byte, err error) {
+	if privPem, err = MarshalPrivateKeyPem(key); err != nil {
+		return nil, nil, err
+	}
+	if pubPem, err = MarshalPublicKeyPem(key.Public()); err != nil {
+		return nil, nil, err
+	}
+	return privPem, pubPem, nil
+}
+
+// GenXxxKey使用,覆盖已存在的文件
+func writeKeyPair(key crypto.Signer, private, public string) error {
+	return WriteKeyFiles(key, private, public, &KeyFileOptions{Overwrite: true})
+}
+
+/*
+将密钥写入文件,私钥权限为0600,公钥权限为0644
+先写入同目录下的临时文件再重命名,任意一步失败都不会留下写了一半的密钥文件
+opt为nil时不覆盖已存在的文件
+*/
+func WriteKeyFiles(key crypto.Signer, private, public string, opt *KeyFileOptions) error {
+	if opt == nil {
+		opt = new(KeyFileOptions)
+	}
+	privPem, pubPem, err := marshalKeyPair(key)
+	if err != nil {
+		return err
+	}
+
+	files := []atomicFile{
+		{name: private, data: privPem, perm: 0600},
+		{name: public, data: pubPem, perm: 0644},
+	}
+	if opt.SSHPublic != "" {
+		sshKey, err := ssh.NewPublicKey(key.Public())
+		if err != nil {
+			return err
+		}
+		files = append(files, atomicFile{
+			name: opt.SSHPublic, data: ssh.MarshalAuthorizedKey(sshKey), perm: 0644})
+	}
+	return writeFilesAtomic(files, opt.Overwrite)
+}
+
+type atomicFile struct {
+	name string
+	data []byte
+	perm os.FileMode
+}
+
+// 先全部写入临时文件再逐个重命名,失败时删除新生成的文件,被覆盖的文件恢复为原来的内容
+func writeFilesAtomic(files []atomicFile, overwrite bool) error {
+	if !overwrite { // 生成任何文件之前先检查
+		for _, f := range files {
+			if _, err := os.Lstat(f.name); err == nil {
+				return ErrKeyFileExists
+			}
+		}
+	}
+
+	var (
+		tmps = make([]string, len(files))
+		baks = make([]string, len(files)) // 覆盖模式下已存在文件的备份
+	)
+	defer func() {
+		for _, tmp := range append(tmps, baks...) {
+			if tmp != "" {
+				os.Remove(tmp)
+			}
+		}
+	}()
+	for i, f := range files {
+		var err error
+		if tmps[i], err = writeTempFile(f.name, f.data, f.perm); err != nil {
+			return err
+		}
+		if overwrite {
+			if baks[i], err = backupFile(f.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, f := range files {
+		if err := renameFile(tmps[i], f.name, overwrite); err != nil {
+			for j, done := range files[:i] { // 回滚已经生成的文件
+				if baks[j] != "" {
+					os.Rename(baks[j], done.name)
+				} else {
+					os.Remove(done.name)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// 复制已存在的普通文件到同目录下的临时文件,文件不存在时返回空字符串
+func backupFile(name string) (string, error) {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !fi.Mode().IsRegular() { // 目录等无法被覆盖,重命名时会失败
+		return "", nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return writeTempFile(name, data, fi.Mode().Perm())
+}
+
+// 在name同目录下写入临时文件并刷到磁盘,返回临时文件名
+func writeTempFile(name string, data []byte, perm os.FileMode) (string, error) {
+	return createTempFile(name, perm, func(fw *os.File) error {
+		_, err := fw.Write(data)
+		return err
+	})
+}
+
+// 在name同目录下创建临时文件,由fn写入内容后刷到磁盘,失败时删除临时文件
+func createTempFile(name string, perm os.FileMode, fn func(fw *os.File) error) (string, error) {
+	fw, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp*")
+	if err != nil {
+		return "", err
+	}
+	tmp := fw.Name()
+	if err = fw.Chmod(perm); err == nil {
+		if err = fn(fw); err == nil {
+			err = fw.Sync()
+		}
+	}
+	if closeErr := fw.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return tmp, nil
+}
+
+// overwrite为false时使用硬链接实现不覆盖的原子重命名
+func renameFile(tmp, name string, overwrite bool) error {
+	if overwrite {
+		return os.Rename(tmp, name)
+	}
+	err := os.Link(tmp, name)
+	if err == nil {
+		return os.Remove(tmp)
+	}
+	if errors.Is(err, fs.ErrExist) {
+		return ErrKeyFileExists
+	}
+	// 文件系统不支持硬链接时退化为检查后重命名
+	if _, err = os.Lstat(name); err == nil {
+		return ErrKeyFileExists
+	}
+	return os.Rename(tmp, name)
+}
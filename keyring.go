@@ -0,0 +1,248 @@
+package golibs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+/*
+KeyRing加密格式
+
+	版本号(1字节) | 密钥id长度(1字节) | 密钥id | nonce | 密文 | tag
+
+头部参与GCM认证,解密时根据密钥id选择密钥,轮换密钥后旧数据仍可解密
+
+配置文件支持json和pem两种格式
+json:
+
+	{"active": "2024q2", "keys": {"2024q1": "base64密钥", "2024q2": "base64密钥"}}
+
+pem: 每个密钥一个块,Active头部为true的作为当前密钥
+
+	-----BEGIN AES KEY-----
+	Id: 2024q2
+	Active: true
+
+	base64密钥
+	-----END AES KEY-----
+*/
+const (
+	KeyRingVersion byte = 1
+	KeyRingPemType      = "AES KEY"
+)
+
+var (
+	ErrKeyRingVersion  = errors.New("key ring version error")
+	ErrKeyRingEnvelope = errors.New("key ring envelope error")
+	ErrKeyRingKeyId    = errors.New("key ring key id error")
+	ErrKeyRingNotFound = errors.New("key ring key not found")
+	ErrKeyRingNoActive = errors.New("key ring no active key")
+	ErrKeyRingFormat   = errors.New("key ring file format error")
+)
+
+// 按id保存多个AES密钥,加密使用当前密钥,解密按密文中的id选择密钥
+// 实现了watcher.UserProfile接口,可以通过watcher.Register热加载配置文件
+type KeyRing struct {
+	name   string
+	active string
+	keys   map[string][]byte
+	mu     sync.RWMutex
+}
+
+// name为配置文件名,用于watcher.Register
+func NewKeyRing(name string) *KeyRing {
+	return &KeyRing{name: name, keys: make(map[string][]byte)}
+}
+
+// 从json或pem文件加载密钥
+func LoadKeyRing(path string) (*KeyRing, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k := NewKeyRing(filepath.Base(path))
+	if err = k.Modify(data); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *KeyRing) Name() string {
+	return k.name
+}
+
+// 解析配置文件内容并整体替换密钥,解析失败时保持原有密钥不变
+func (k *KeyRing) Modify(data []byte) error {
+	active, keys, err := parseKeyRing(data)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.active, k.keys = active, keys
+	k.mu.Unlock()
+	return nil
+}
+
+// 添加密钥,id已存在时替换
+func (k *KeyRing) Add(id string, key []byte) error {
+	if err := checkKeyRingKey(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	k.keys[id] = append([]byte(nil), key...)
+	k.mu.Unlock()
+	return nil
+}
+
+// 设置加密使用的密钥
+func (k *KeyRing) SetActive(id string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.keys[id]; !ok {
+		return ErrKeyRingNotFound
+	}
+	k.active = id
+	return nil
+}
+
+func (k *KeyRing) Active() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// 使用当前密钥加密,密文中记录密钥id
+func (k *KeyRing) Encrypt(origData, additionalData []byte) ([]byte, error) {
+	k.mu.RLock()
+	id, key := k.active, k.keys[k.active]
+	k.mu.RUnlock()
+	if key == nil {
+		return nil, ErrKeyRingNoActive
+	}
+
+	header := make([]byte, 2+len(id))
+	header[0] = KeyRingVersion
+	header[1] = byte(len(id))
+	copy(header[2:], id)
+	return aesGcmSeal(header, origData, key, additionalData)
+}
+
+// 根据密文中的密钥id选择密钥解密
+func (k *KeyRing) Decrypt(cryptEd, additionalData []byte) ([]byte, error) {
+	id, err := KeyRingId(cryptEd)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.RLock()
+	key := k.keys[id]
+	k.mu.RUnlock()
+	if key == nil {
+		return nil, ErrKeyRingNotFound
+	}
+	headerLen := 2 + len(id)
+	return aesGcmOpen(cryptEd[:headerLen], cryptEd[headerLen:], key, additionalData)
+}
+
+// 获取密文使用的密钥id
+func KeyRingId(cryptEd []byte) (string, error) {
+	if len(cryptEd) < 2 {
+		return "", ErrKeyRingEnvelope
+	}
+	if cryptEd[0] != KeyRingVersion {
+		return "", ErrKeyRingVersion
+	}
+	headerLen := 2 + int(cryptEd[1])
+	if cryptEd[1] == 0 || len(cryptEd) < headerLen {
+		return "", ErrKeyRingEnvelope
+	}
+	return string(cryptEd[2:headerLen]), nil
+}
+
+func checkKeyRingKey(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return ErrKeyRingKeyId
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	}
+	return ErrKeyRingFormat
+}
+
+func parseKeyRing(data []byte) (string, map[string][]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		return parseKeyRingJson(data)
+	}
+	return parseKeyRingPem(data)
+}
+
+func parseKeyRingJson(data []byte) (string, map[string][]byte, error) {
+	var ring struct {
+		Active string            `json:"active"`
+		Keys   map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &ring); err != nil {
+		return "", nil, err
+	}
+
+	keys := make(map[string][]byte, len(ring.Keys))
+	for id, s := range ring.Keys {
+		key, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", nil, err
+		}
+		if err = checkKeyRingKey(id, key); err != nil {
+			return "", nil, err
+		}
+		keys[id] = key
+	}
+	if _, ok := keys[ring.Active]; !ok {
+		return "", nil, ErrKeyRingNoActive
+	}
+	return ring.Active, keys, nil
+}
+
+func parseKeyRingPem(data []byte) (string, map[string][]byte, error) {
+	var (
+		active string
+		block  *pem.Block
+		keys   = make(map[string][]byte)
+	)
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != KeyRingPemType {
+			continue
+		}
+		id := block.Headers["Id"]
+		if err := checkKeyRingKey(id, block.Bytes); err != nil {
+			return "", nil, err
+		}
+		if _, ok := keys[id]; ok {
+			return "", nil, ErrKeyRingKeyId
+		}
+		keys[id] = block.Bytes
+		if block.Headers["Active"] == "true" {
+			if active != "" {
+				return "", nil, ErrKeyRingFormat
+			}
+			active = id
+		}
+	}
+	if len(keys) == 0 {
+		return "", nil, ErrKeyRingFormat
+	}
+	if active == "" {
+		return "", nil, ErrKeyRingNoActive
+	}
+	return active, keys, nil
+}
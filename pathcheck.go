@@ -0,0 +1,163 @@
+package golibs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+var (
+	ErrPathNotExist   = errors.New("path not exist")
+	ErrPathPermission = errors.New("path permission denied")
+	ErrPathType       = errors.New("path type error")
+	ErrPathEmpty      = errors.New("path is empty")
+	ErrPathNotEmpty   = errors.New("path is not empty")
+)
+
+// 检查路径失败时返回,可通过errors.Is判断上面的错误
+// 由系统调用产生的错误同时可以用errors.Is(err, fs.ErrNotExist)等判断
+type PathError struct {
+	Op   string // stat,type,read,write,exec,empty
+	Path string
+	Err  error // 上面定义的错误
+	Sys  error // 系统调用返回的原始错误,可能为nil
+}
+
+func (e *PathError) Error() string {
+	if e.Sys != nil {
+		return e.Op + " " + e.Path + ": " + e.Err.Error() + ": " + e.Sys.Error()
+	}
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Is(target error) bool {
+	return target == e.Err
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Sys
+}
+
+type PathType int
+
+const (
+	PathAny     PathType = iota
+	PathFile             // 普通文件
+	PathDir              // 目录
+	PathSymlink          // 符号链接,需要同时设置NoFollow
+	PathNotDir           // 不是目录,包括设备,管道,套接字等特殊文件
+)
+
+type PathOptions struct {
+	Type     PathType
+	NoFollow bool // 不跟随符号链接,检查链接本身
+
+	// 按当前进程的用户检查权限,总是作用于链接指向的文件
+	Readable   bool
+	Writable   bool
+	Executable bool // 目录表示可以进入
+
+	// 文件大小为0或目录下没有文件,只能用于普通文件和目录
+	Empty    bool
+	NotEmpty bool
+}
+
+// 按opt检查路径,opt为nil时只检查路径存在,成功时返回文件信息
+func CheckPath(path string, opt *PathOptions) (os.FileInfo, error) {
+	if opt == nil {
+		opt = new(PathOptions)
+	}
+
+	var (
+		fi  os.FileInfo
+		err error
+	)
+	if opt.NoFollow {
+		fi, err = os.Lstat(path)
+	} else {
+		fi, err = os.Stat(path)
+	}
+	if err != nil {
+		return nil, newPathError("stat", path, err)
+	}
+
+	var typeOk bool
+	switch opt.Type {
+	case PathFile:
+		typeOk = fi.Mode().IsRegular()
+	case PathDir:
+		typeOk = fi.IsDir()
+	case PathSymlink:
+		typeOk = fi.Mode()&fs.ModeSymlink != 0
+	case PathNotDir:
+		typeOk = !fi.IsDir()
+	default:
+		typeOk = true
+	}
+	if !typeOk {
+		return nil, &PathError{Op: "type", Path: path, Err: ErrPathType}
+	}
+
+	for _, c := range []struct {
+		check bool
+		op    string
+		mode  uint32
+	}{
+		{opt.Readable, "read", pathAccessRead},
+		{opt.Writable, "write", pathAccessWrite},
+		{opt.Executable, "exec", pathAccessExec},
+	} {
+		if c.check {
+			if err = pathAccess(path, fi, c.mode); err != nil {
+				return nil, newPathError(c.op, path, err)
+			}
+		}
+	}
+
+	if opt.Empty || opt.NotEmpty {
+		empty, err := isPathEmpty(path, fi)
+		if err != nil {
+			return nil, newPathError("empty", path, err)
+		}
+		if opt.Empty && !empty {
+			return nil, &PathError{Op: "empty", Path: path, Err: ErrPathNotEmpty}
+		}
+		if opt.NotEmpty && empty {
+			return nil, &PathError{Op: "empty", Path: path, Err: ErrPathEmpty}
+		}
+	}
+	return fi, nil
+}
+
+func newPathError(op, path string, err error) error {
+	e := &PathError{Op: op, Path: path, Err: err, Sys: err}
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		e.Err = ErrPathNotExist
+	case errors.Is(err, fs.ErrPermission):
+		e.Err = ErrPathPermission
+	case errors.Is(err, ErrPathType):
+		e.Err, e.Sys = ErrPathType, nil
+	}
+	return e
+}
+
+func isPathEmpty(path string, fi os.FileInfo) (bool, error) {
+	if fi.Mode().IsRegular() {
+		return fi.Size() == 0, nil
+	}
+	if !fi.IsDir() {
+		return false, ErrPathType
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,17 @@
+package golibs
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	pathAccessRead  = 0x4 // R_OK
+	pathAccessWrite = 0x2 // W_OK
+	pathAccessExec  = 0x1 // X_OK
+)
+
+// 使用access检查权限,与shell中test -r等结果一致
+func pathAccess(path string, _ os.FileInfo, mode uint32) error {
+	return syscall.Access(path, mode)
+}
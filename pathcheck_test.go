@@ -0,0 +1,62 @@
+package golibs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// go test . -v -run Path
+
+func TestCheckPath(t *testing.T) {
+	dir := t.TempDir()
+	Convey("test check path", t, func() {
+		file := filepath.Join(dir, "a.txt")
+		So(os.WriteFile(file, []byte("hello"), 0644), ShouldBeNil)
+
+		fi, err := CheckPath(file, &PathOptions{Type: PathFile, Readable: true, Writable: true, NotEmpty: true})
+		So(err, ShouldBeNil)
+		So(fi.Size(), ShouldEqual, 5)
+
+		_, err = CheckPath(filepath.Join(dir, "none"), nil)
+		So(errors.Is(err, ErrPathNotExist), ShouldBeTrue)
+		So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+
+		_, err = CheckPath(file, &PathOptions{Type: PathDir})
+		So(errors.Is(err, ErrPathType), ShouldBeTrue)
+		So(IsFilePathExists(file, true), ShouldBeNil)
+		So(errors.Is(IsFilePathExists(file, false), ErrPathType), ShouldBeTrue)
+		So(errors.Is(IsFilePathExists(dir, true), ErrPathType), ShouldBeTrue)
+		So(IsFilePathExists(dir, false), ShouldBeNil)
+		if runtime.GOOS != "windows" { // 设备文件不是普通文件,但也不是目录
+			So(IsFilePathExists(os.DevNull, true), ShouldBeNil)
+			_, err = CheckPath(os.DevNull, &PathOptions{Type: PathFile})
+			So(errors.Is(err, ErrPathType), ShouldBeTrue)
+		}
+
+		_, err = CheckPath(file, &PathOptions{Empty: true})
+		So(errors.Is(err, ErrPathNotEmpty), ShouldBeTrue)
+
+		sub := filepath.Join(dir, "sub")
+		So(os.Mkdir(sub, 0755), ShouldBeNil)
+		_, err = CheckPath(sub, &PathOptions{Type: PathDir, Empty: true, Executable: true})
+		So(err, ShouldBeNil)
+		_, err = CheckPath(sub, &PathOptions{NotEmpty: true})
+		So(errors.Is(err, ErrPathEmpty), ShouldBeTrue)
+
+		link := filepath.Join(dir, "link")
+		if os.Symlink(file, link) == nil {
+			_, err = CheckPath(link, &PathOptions{Type: PathFile})
+			So(err, ShouldBeNil)
+			_, err = CheckPath(link, &PathOptions{Type: PathSymlink, NoFollow: true})
+			So(err, ShouldBeNil)
+			_, err = CheckPath(link, &PathOptions{Type: PathFile, NoFollow: true})
+			So(errors.Is(err, ErrPathType), ShouldBeTrue)
+		}
+	})
+}
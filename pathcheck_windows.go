@@ -0,0 +1,64 @@
+package golibs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	pathAccessRead = 1 << iota
+	pathAccessWrite
+	pathAccessExec
+)
+
+// windows没有access,尽量通过实际打开文件判断,不检查ACL中的执行权限
+func pathAccess(path string, fi os.FileInfo, mode uint32) error {
+	switch mode {
+	case pathAccessRead:
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	case pathAccessWrite:
+		if fi.IsDir() { // 目录的只读属性不限制创建文件,尝试创建临时文件
+			f, err := os.CreateTemp(path, ".access")
+			if err != nil {
+				return err
+			}
+			f.Close()
+			return os.Remove(f.Name())
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	case pathAccessExec:
+		if fi.IsDir() || pathExecutableExt(path) {
+			return nil
+		}
+		return fs.ErrPermission
+	}
+	return nil
+}
+
+// 根据PATHEXT判断是否为可执行文件
+func pathExecutableExt(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return false
+	}
+	exts := os.Getenv("PATHEXT")
+	if exts == "" {
+		exts = ".com;.exe;.bat;.cmd"
+	}
+	for _, e := range strings.Split(strings.ToLower(exts), ";") {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package golibs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// openssl 3默认使用的PKCS#8加密格式(RFC 8018 PBES2)
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHmacSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHmacSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHmacSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHmacSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algo          pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type scryptParams struct {
+	Salt            []byte
+	CostParameter   int
+	BlockSize       int
+	Parallelization int
+	KeyLength       int `asn1:"optional"`
+}
+
+// 解密PKCS#8 EncryptedPrivateKeyInfo,返回PrivateKeyInfo的der数据
+func decryptPKCS8(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 {
+		return nil, ErrKeyType
+	}
+	if !info.Algo.Algorithm.Equal(oidPBES2) {
+		return nil, ErrKeyType
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algo.Parameters.FullBytes, &params); err != nil {
+		return nil, ErrKeyType
+	}
+
+	var keyLen int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLen = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLen = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, ErrKeyType
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil ||
+		len(iv) != aes.BlockSize {
+		return nil, ErrKeyType
+	}
+
+	key, err := pkcs8DeriveKey(params.KeyDerivationFunc, password, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrKeyType
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	if out, err = PKCS7UnPadding(out, aes.BlockSize); err != nil {
+		return nil, ErrKeyPassword
+	}
+	return out, nil
+}
+
+func pkcs8DeriveKey(kdf pkix.AlgorithmIdentifier, password []byte, keyLen int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, ErrKeyType
+		}
+		if params.IterationCount <= 0 || params.IterationCount > 1<<24 ||
+			(params.KeyLength != 0 && params.KeyLength != keyLen) {
+			return nil, ErrKeyType
+		}
+
+		var h func() hash.Hash
+		switch prf := params.PRF.Algorithm; {
+		case len(prf) == 0, prf.Equal(oidHmacSHA1): // 缺省值为HMAC-SHA1
+			h = sha1.New
+		case prf.Equal(oidHmacSHA256):
+			h = sha256.New
+		case prf.Equal(oidHmacSHA384):
+			h = sha512.New384
+		case prf.Equal(oidHmacSHA512):
+			h = sha512.New
+		default:
+			return nil, ErrKeyType
+		}
+		return pbkdf2.Key(password, params.Salt, params.IterationCount, keyLen, h), nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, ErrKeyType
+		}
+		if (params.KeyLength != 0 && params.KeyLength != keyLen) ||
+			params.CostParameter <= 0 || params.BlockSize <= 0 || params.Parallelization <= 0 ||
+			!scryptParamsOk(uint64(params.CostParameter), uint64(params.BlockSize),
+				uint64(params.Parallelization)) {
+			return nil, ErrKeyType // 与口令加密相同的内存限制,避免恶意私钥文件耗尽内存
+		}
+		return DeriveKey(password, params.Salt, keyLen, &KdfParams{
+			Type:    KdfScrypt,
+			Cost:    uint32(params.CostParameter),
+			Memory:  uint32(params.BlockSize),
+			Threads: uint32(params.Parallelization),
+		})
+	}
+	return nil, ErrKeyType
+}
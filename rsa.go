@@ -1,79 +1,121 @@
 package golibs
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"errors"
-	"os"
 )
 
+type RsaSignType int
+
+const (
+	RsaPKCS1v15 RsaSignType = iota
+	RsaPSS
+)
+
+var ErrRsaHash = errors.New("rsa hash not supported")
+
 func GenRsaKey(bits int, private, public string) error {
 	privateKey, err := rsa.GenerateKey(rand.Reader, bits) // 生成私钥文件
 	if err != nil {
 		return err
 	}
-	derStream := x509.MarshalPKCS1PrivateKey(privateKey)
-	block := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: derStream,
-	}
-	privFile, err := os.Create(private)
+	return writeKeyPair(privateKey, private, public)
+}
+
+// 加密
+func RsaEncrypt(origData, publicKey []byte) ([]byte, error) {
+	pub, err := ParseRsaPublicKey(publicKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer privFile.Close()
+	return rsa.EncryptPKCS1v15(rand.Reader, pub, origData)
+}
+
+// 解密
+func RsaDecrypt(cipherText, privateKey []byte) ([]byte, error) {
+	return RsaDecryptPassword(cipherText, privateKey, nil)
+}
 
-	err = pem.Encode(privFile, block)
+// 使用加密的私钥解密,password为私钥口令
+func RsaDecryptPassword(cipherText, privateKey, password []byte) ([]byte, error) {
+	priv, err := ParseRsaPrivateKey(privateKey, password)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return rsa.DecryptPKCS1v15(rand.Reader, priv, cipherText)
+}
 
-	publicKey := &privateKey.PublicKey
-	derPkix, err := x509.MarshalPKIXPublicKey(publicKey) // 生成公钥文件
+// OAEP加密,hash常用crypto.SHA256,label可为nil,解密时需使用相同的hash和label
+func RsaEncryptOAEP(origData, publicKey []byte, hash crypto.Hash, label []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, ErrRsaHash
+	}
+	pub, err := ParseRsaPublicKey(publicKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	block = &pem.Block{
-		Type:  "PUBLIC KEY",
-		Bytes: derPkix,
+	return rsa.EncryptOAEP(hash.New(), rand.Reader, pub, origData, label)
+}
+
+// OAEP解密
+func RsaDecryptOAEP(cipherText, privateKey []byte, hash crypto.Hash, label []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, ErrRsaHash
 	}
-	pubFile, err := os.Create(public)
+	priv, err := ParseRsaPrivateKey(privateKey, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer pubFile.Close()
-	err = pem.Encode(pubFile, block)
+	return rsa.DecryptOAEP(hash.New(), rand.Reader, priv, cipherText, label)
+}
+
+// 签名,hash支持crypto.SHA256/SHA384/SHA512
+func RsaSign(origData, privateKey []byte, hash crypto.Hash, st RsaSignType) ([]byte, error) {
+	priv, err := ParseRsaPrivateKey(privateKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return (&RsaSigner{Key: priv, Hash: hash, Type: st}).Sign(origData)
+}
+
+// 验签,验证失败返回ErrVerification
+func RsaVerify(origData, sig, publicKey []byte, hash crypto.Hash, st RsaSignType) error {
+	pub, err := ParseRsaPublicKey(publicKey)
 	if err != nil {
 		return err
 	}
-	return nil
+	return (&RsaVerifier{Key: pub, Hash: hash, Type: st}).Verify(origData, sig)
 }
 
-// 加密
-func RsaEncrypt(origData, publicKey []byte) ([]byte, error) {
-	block, _ := pem.Decode(publicKey)
-	if block == nil {
-		return nil, errors.New("public key error")
+func rsaDigest(origData []byte, hash crypto.Hash) ([]byte, error) {
+	switch hash {
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		return nil, ErrRsaHash
 	}
-	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	h := hash.New()
+	h.Write(origData)
+	return h.Sum(nil), nil
+}
+
+// 加密并使用c编码密文
+func RsaEncryptCodec(origData, publicKey []byte, c Codec) (string, error) {
+	cryptEd, err := RsaEncrypt(origData, publicKey)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	pub := pubInterface.(*rsa.PublicKey)
-	return rsa.EncryptPKCS1v15(rand.Reader, pub, origData)
+	return c.EncodeToString(cryptEd), nil
 }
 
-// 解密
-func RsaDecrypt(cipherText, privateKey []byte) ([]byte, error) {
-	block, _ := pem.Decode(privateKey)
-	if block == nil {
-		return nil, errors.New("private key error")
-	}
-	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+// 使用c解码密文后解密
+func RsaDecryptCodec(cipherText string, privateKey []byte, c Codec) ([]byte, error) {
+	byt, err := c.DecodeString(cipherText)
 	if err != nil {
 		return nil, err
 	}
-	return rsa.DecryptPKCS1v15(rand.Reader, priv, cipherText)
+	return RsaDecrypt(byt, privateKey)
 }
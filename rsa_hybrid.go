@@ -0,0 +1,61 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+/*
+混合加密格式,用于加密超过rsa密钥长度的数据
+
+	版本号(1字节) | 加密后数据密钥长度(2字节) | RSA-OAEP(SHA256)加密后的数据密钥 | nonce | 密文 | tag
+
+每次加密随机生成AES-256数据密钥,头部参与GCM认证
+*/
+const RsaHybridVersion byte = 1
+
+var (
+	ErrRsaHybridVersion  = errors.New("rsa hybrid version error")
+	ErrRsaHybridEnvelope = errors.New("rsa hybrid envelope error")
+)
+
+// 使用公钥加密任意长度数据
+func RsaHybridEncrypt(origData, publicKey []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	wrapped, err := RsaEncryptOAEP(dataKey, publicKey, crypto.SHA256, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 3+len(wrapped))
+	header[0] = RsaHybridVersion
+	binary.BigEndian.PutUint16(header[1:], uint16(len(wrapped)))
+	copy(header[3:], wrapped)
+	return aesGcmSeal(header, origData, dataKey, nil)
+}
+
+// 使用私钥解密RsaHybridEncrypt的结果
+func RsaHybridDecrypt(cipherText, privateKey []byte) ([]byte, error) {
+	if len(cipherText) < 3 {
+		return nil, ErrRsaHybridEnvelope
+	}
+	if cipherText[0] != RsaHybridVersion {
+		return nil, ErrRsaHybridVersion
+	}
+	headerLen := 3 + int(binary.BigEndian.Uint16(cipherText[1:]))
+	if len(cipherText) < headerLen {
+		return nil, ErrRsaHybridEnvelope
+	}
+
+	dataKey, err := RsaDecryptOAEP(cipherText[3:headerLen], privateKey, crypto.SHA256, nil)
+	if err != nil {
+		return nil, err
+	}
+	return aesGcmOpen(cipherText[:headerLen], cipherText[headerLen:], dataKey, nil)
+}
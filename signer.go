@@ -0,0 +1,188 @@
+package golibs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+)
+
+var ErrVerification = errors.New("verification error")
+
+// 签名接口,RSA/ECDSA/Ed25519均实现了该接口
+type Signer interface {
+	Sign(origData []byte) ([]byte, error)
+	Public() crypto.PublicKey
+}
+
+// 验签接口,验证失败返回ErrVerification
+type Verifier interface {
+	Verify(origData, sig []byte) error
+}
+
+// 根据pem私钥类型创建Signer,rsa默认使用PKCS#1 v1.5 + SHA256
+func NewSigner(privateKey, password []byte) (Signer, error) {
+	key, err := ParsePrivateKey(privateKey, password)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &RsaSigner{Key: k, Hash: crypto.SHA256}, nil
+	case *ecdsa.PrivateKey:
+		return &EcdsaSigner{Key: k}, nil
+	case ed25519.PrivateKey:
+		return Ed25519Signer{Key: k}, nil
+	}
+	return nil, &KeyError{Err: ErrKeyType}
+}
+
+// 根据pem公钥或证书类型创建Verifier,rsa默认使用PKCS#1 v1.5 + SHA256
+func NewVerifier(publicKey []byte) (Verifier, error) {
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &RsaVerifier{Key: k, Hash: crypto.SHA256}, nil
+	case *ecdsa.PublicKey:
+		return &EcdsaVerifier{Key: k}, nil
+	case ed25519.PublicKey:
+		return Ed25519Verifier{Key: k}, nil
+	}
+	return nil, &KeyError{Err: ErrKeyType}
+}
+
+/*----------------------------------------------------------------------------*/
+
+// Hash支持crypto.SHA256/SHA384/SHA512
+type RsaSigner struct {
+	Key  *rsa.PrivateKey
+	Hash crypto.Hash
+	Type RsaSignType
+}
+
+func (s *RsaSigner) Sign(origData []byte) ([]byte, error) {
+	digest, err := rsaDigest(origData, s.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if s.Type == RsaPSS {
+		return rsa.SignPSS(rand.Reader, s.Key, s.Hash, digest,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+	return rsa.SignPKCS1v15(rand.Reader, s.Key, s.Hash, digest)
+}
+
+func (s *RsaSigner) Public() crypto.PublicKey {
+	return &s.Key.PublicKey
+}
+
+type RsaVerifier struct {
+	Key  *rsa.PublicKey
+	Hash crypto.Hash
+	Type RsaSignType
+}
+
+func (v *RsaVerifier) Verify(origData, sig []byte) error {
+	digest, err := rsaDigest(origData, v.Hash)
+	if err != nil {
+		return err
+	}
+	if v.Type == RsaPSS {
+		// 兼容其他实现使用的任意salt长度
+		err = rsa.VerifyPSS(v.Key, v.Hash, digest, sig,
+			&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	} else {
+		err = rsa.VerifyPKCS1v15(v.Key, v.Hash, digest, sig)
+	}
+	if err != nil {
+		return ErrVerification
+	}
+	return nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+// 签名为ASN.1格式,P-256使用SHA256,P-384使用SHA384,P-521使用SHA512
+type EcdsaSigner struct {
+	Key *ecdsa.PrivateKey
+}
+
+func (s *EcdsaSigner) Sign(origData []byte) ([]byte, error) {
+	digest, err := ecdsaDigest(s.Key.Curve, origData)
+	if err != nil {
+		return nil, err
+	}
+	return ecdsa.SignASN1(rand.Reader, s.Key, digest)
+}
+
+func (s *EcdsaSigner) Public() crypto.PublicKey {
+	return &s.Key.PublicKey
+}
+
+type EcdsaVerifier struct {
+	Key *ecdsa.PublicKey
+}
+
+func (v *EcdsaVerifier) Verify(origData, sig []byte) error {
+	digest, err := ecdsaDigest(v.Key.Curve, origData)
+	if err != nil {
+		return err
+	}
+	if !ecdsa.VerifyASN1(v.Key, digest, sig) {
+		return ErrVerification
+	}
+	return nil
+}
+
+func ecdsaHash(curve elliptic.Curve) (crypto.Hash, error) {
+	switch curve {
+	case elliptic.P256():
+		return crypto.SHA256, nil
+	case elliptic.P384():
+		return crypto.SHA384, nil
+	case elliptic.P521():
+		return crypto.SHA512, nil
+	}
+	return 0, ErrKeyType
+}
+
+func ecdsaDigest(curve elliptic.Curve, origData []byte) ([]byte, error) {
+	hash, err := ecdsaHash(curve)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(origData)
+	return h.Sum(nil), nil
+}
+
+/*----------------------------------------------------------------------------*/
+
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+}
+
+func (s Ed25519Signer) Sign(origData []byte) ([]byte, error) {
+	return ed25519.Sign(s.Key, origData), nil
+}
+
+func (s Ed25519Signer) Public() crypto.PublicKey {
+	return s.Key.Public()
+}
+
+type Ed25519Verifier struct {
+	Key ed25519.PublicKey
+}
+
+func (v Ed25519Verifier) Verify(origData, sig []byte) error {
+	if !ed25519.Verify(v.Key, origData, sig) {
+		return ErrVerification
+	}
+	return nil
+}
@@ -1,31 +1,88 @@
 package golibs
 
 import (
-    "crypto/md5"
-    "encoding/hex"
-    "errors"
+    "crypto/hmac"
+    "crypto/subtle"
+    "hash"
     "io"
-    "os"
+    "strings"
     "unsafe"
 )
 
+// isFile为true时s为文件路径,否则计算s的md5
+// 其他算法请使用HashBytes,HashReader和HashFile
 func Md5sum(s string, isFile bool) (string, error) {
-    h := md5.New()
     if isFile {
-        fr, err := os.Open(s)
-        if err != nil {
-            return "", err
-        }
-        defer fr.Close()
-        if _, err = io.Copy(h, fr); err != nil {
-            return "", err
-        }
-    } else {
-        if _, err := h.Write([]byte(s)); err != nil {
-            return "", err
-        }
-    }
-    return hex.EncodeToString(h.Sum(nil)), nil
+        return HashFile(HashMD5, s)
+    }
+    return HashBytes(HashMD5, StringToBytes(s))
+}
+
+func hmacHash(alg string) (func() hash.Hash, error) {
+    switch alg = strings.ToLower(alg); alg {
+    case HashSHA1, HashSHA256, HashSHA512: // sha1只用于兼容旧的webhook
+        return hashFuncs[alg], nil
+    }
+    return nil, ErrHashAlg
+}
+
+// 计算HMAC,alg支持HashSHA256,HashSHA512和HashSHA1
+func HmacSum(alg string, key, data []byte) ([]byte, error) {
+    fn, err := hmacHash(alg)
+    if err != nil {
+        return nil, err
+    }
+    h := hmac.New(fn, key)
+    h.Write(data)
+    return h.Sum(nil), nil
+}
+
+// 读取r直到EOF并计算HMAC
+func HmacReader(alg string, key []byte, r io.Reader) ([]byte, error) {
+    fn, err := hmacHash(alg)
+    if err != nil {
+        return nil, err
+    }
+    h := hmac.New(fn, key)
+    if _, err = io.Copy(h, r); err != nil {
+        return nil, err
+    }
+    return h.Sum(nil), nil
+}
+
+// 计算HMAC并使用c编码,例如HexCodec,StdCodec
+func HmacString(alg string, key []byte, data string, c Codec) (string, error) {
+    mac, err := HmacSum(alg, key, StringToBytes(data))
+    if err != nil {
+        return "", err
+    }
+    return c.EncodeToString(mac), nil
+}
+
+// 校验HMAC,使用常量时间比较
+func HmacVerify(alg string, key, data, mac []byte) bool {
+    expect, err := HmacSum(alg, key, data)
+    return err == nil && hmac.Equal(expect, mac)
+}
+
+// 读取r直到EOF并校验HMAC
+func HmacVerifyReader(alg string, key []byte, r io.Reader, mac []byte) (bool, error) {
+    expect, err := HmacReader(alg, key, r)
+    if err != nil {
+        return false, err
+    }
+    return hmac.Equal(expect, mac), nil
+}
+
+// 校验使用c编码的HMAC,例如webhook请求头中的签名
+func HmacVerifyString(alg string, key []byte, data, mac string, c Codec) bool {
+    byt, err := c.DecodeString(mac)
+    return err == nil && HmacVerify(alg, key, StringToBytes(data), byt)
+}
+
+// 常量时间比较字符串,用于比较token等敏感数据,不要使用==
+func ConstantTimeEqual(a, b string) bool {
+    return subtle.ConstantTimeCompare(StringToBytes(a), StringToBytes(b)) == 1
 }
 
 // 由于共用内存,转换后的[]byte不可写
@@ -38,20 +95,13 @@ func BytesToString(b []byte) string {
     return *(*string)(unsafe.Pointer(&b))
 }
 
-// 判断文件或目录存在且类型正确
+// 判断文件或目录存在且类型正确,isFile为true时只要不是目录即可
+// 更多检查请使用CheckPath,返回的错误可通过errors.Is(err, ErrPathNotExist)等判断
 func IsFilePathExists(path string, isFile bool) error {
-    if path == "" {
-        return errors.New("path is nil")
-    }
-    f, err := os.Stat(path)
-    if err != nil {
-        return err
-    }
-    if isFile != f.IsDir() {
-        return nil
-    }
+    opt := &PathOptions{Type: PathDir}
     if isFile {
-        return errors.New(path + " not file")
+        opt.Type = PathNotDir
     }
-    return errors.New(path + " not dir")
+    _, err := CheckPath(path, opt)
+    return err
 }